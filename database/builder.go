@@ -0,0 +1,214 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/service/search"
+	"github.com/jmoiron/sqlx"
+)
+
+// queryBuilder - построитель запроса SELECT. Тексты условий задаются только в коде, значения
+// передаются в запрос именованными параметрами, поэтому условия запроса клиента не попадают в текст SQL
+type queryBuilder struct {
+	from  string
+	where []string
+	args  map[string]any
+}
+
+func newQueryBuilder(from string) *queryBuilder {
+	return &queryBuilder{from: from, args: map[string]any{}}
+}
+
+// bind добавляет в запрос параметр со значением value и возвращает его имя для подстановки в текст запроса
+func (b *queryBuilder) bind(value any) string {
+	name := "p" + strconv.Itoa(len(b.args)+1)
+	b.args[name] = value
+	return ":" + name
+}
+
+// bindAll заменяет знаки "?" в тексте text параметрами со значениями values по порядку
+func (b *queryBuilder) bindAll(text string, values ...any) string {
+	for _, value := range values {
+		text = strings.Replace(text, "?", b.bind(value), 1)
+	}
+	return text
+}
+
+// join добавляет к источнику строк запроса текст join с параметрами values на месте знаков "?"
+func (b *queryBuilder) join(join string, values ...any) {
+	b.from += " " + b.bindAll(join, values...)
+}
+
+// and добавляет условие cond с параметрами values на месте знаков "?"; условия объединяются по И
+func (b *queryBuilder) and(cond string, values ...any) {
+	b.where = append(b.where, "("+b.bindAll(cond, values...)+")")
+}
+
+// whereClause возвращает предложение WHERE; "" - условий нет
+func (b *queryBuilder) whereClause() string {
+	if len(b.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.where, " AND ")
+}
+
+// count возвращает запрос количества строк, удовлетворяющих условиям
+func (b *queryBuilder) count() string {
+	return "SELECT COUNT(*) FROM " + b.from + b.whereClause()
+}
+
+// selectRows возвращает запрос столбцов columns строк, удовлетворяющих условиям, упорядоченных по orderBy,
+// с ограничением количества строк limit и смещением offset
+func (b *queryBuilder) selectRows(columns string, orderBy string, limit int, offset int) string {
+	return "SELECT " + columns + " FROM " + b.from + b.whereClause() + " ORDER BY " + orderBy +
+		" LIMIT " + b.bind(limit) + " OFFSET " + b.bind(offset)
+}
+
+// get выполняет запрос query с параметрами построителя и сканирует единственную строку в dest
+func (b *queryBuilder) get(db *sqlx.DB, dest any, query string) error {
+	query, args, err := db.BindNamed(query, b.args)
+	if err != nil {
+		return err
+	}
+	return db.Get(dest, query, args...)
+}
+
+// selectAll выполняет запрос query с параметрами построителя и сканирует все строки в dest
+func (b *queryBuilder) selectAll(db *sqlx.DB, dest any, query string) error {
+	query, args, err := db.BindNamed(query, b.args)
+	if err != nil {
+		return err
+	}
+	return db.Select(dest, query, args...)
+}
+
+// taskDialect - различия запросов списка задач в SQLite и PostgreSQL
+type taskDialect struct {
+	// fullText добавляет в запрос b поиск задач, для которых выполняются текстовые условия positive и не выполняется
+	// ни одно из условий negative. Слова условий без поля ищутся в поле in (SearchTitle, SearchComment или "" - в обоих
+	// полях). Если условия positive заданы, возвращает столбец фрагмента с выделенными совпадениями и выражение
+	// упорядочения по убыванию релевантности
+	fullText func(b *queryBuilder, positive []search.Term, negative []search.Term, in string) (snippet string, relevance string)
+	// collate - правило сравнения строк, при котором строки упорядочиваются побайтово
+	collate string
+}
+
+// getTasks возвращает страницу списка задач базы данных db по условиям query
+func getTasks(db *sqlx.DB, dialect taskDialect, query TaskQuery) (TaskPage, error) {
+	empty := TaskPage{Tasks: []models.Task{}}
+	if err := query.Validate(); err != nil {
+		return empty, err
+	}
+	after, err := query.after()
+	if err != nil {
+		return empty, err
+	}
+
+	b := newQueryBuilder("scheduler")
+	b.and("deleted_at = '' AND completed_at = ''")
+	columns, relevance := taskColumns, ""
+	if date, ok := query.date(); ok {
+		b.and("date = ?", date)
+	} else if parsed, ok := query.searchQuery(); ok {
+		if len(parsed.Terms) == 0 {
+			return empty, nil
+		}
+		for _, term := range parsed.Terms {
+			if !term.Text() {
+				cond, values := fieldCondition(term)
+				b.and(cond, values...)
+			}
+		}
+		var snippet string
+		snippet, relevance = dialect.fullText(b, parsed.TextTerms(false), parsed.TextTerms(true), query.SearchIn)
+		if snippet != "" {
+			columns += ", " + snippet + " AS snippet"
+		}
+	}
+	if query.From != "" {
+		b.and("date >= ?", query.From)
+	}
+	if query.To != "" {
+		b.and("date <= ?", query.To)
+	}
+	if query.Repeating != nil {
+		repeating := "repeat = ''"
+		if *query.Repeating {
+			repeating = "repeat <> ''"
+		}
+		b.and(repeating)
+	}
+	if query.Overdue != nil {
+		day, clock := query.now()
+		overdue := "date < ? OR date = ? AND time <> '' AND time < ?"
+		if !*query.Overdue {
+			overdue = "NOT (" + overdue + ")"
+		}
+		b.and(overdue, day, day, clock)
+	}
+
+	var total int
+	if err := b.get(db, &total, b.count()); err != nil {
+		return empty, err
+	}
+
+	// задачи упорядочиваются по ключу упорядочения, а при его отсутствии - по релевантности и дате
+	keys, order := query.sortKeys(), []string{}
+	if keys == nil {
+		order = append(order, relevance)
+		keys = []string{"date", "time", "id"}
+	}
+	direction, compare := "", ">"
+	if query.Desc {
+		direction, compare = " DESC", "<"
+	}
+	for i, key := range keys {
+		if key == "title" {
+			keys[i] = key + dialect.collate
+		}
+		order = append(order, keys[i]+direction)
+	}
+	if after.ID > 0 {
+		values := query.keyValues(after)
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		b.and("("+strings.Join(keys, ", ")+") "+compare+" ("+placeholders+")", values...)
+	}
+
+	tasks := []models.Task{}
+	err = b.selectAll(db, &tasks, b.selectRows(columns, strings.Join(order, ", "), query.limit()+1, after.Offset))
+	if err != nil {
+		return empty, err
+	}
+	for i := range tasks {
+		tasks[i].Snippet = snippetHTML(tasks[i].Snippet)
+	}
+	return query.newPage(tasks, after, total), nil
+}
+
+// getTrash возвращает страницу задач из корзины базы данных db по условиям query
+func getTrash(db *sqlx.DB, query TrashQuery) (TaskPage, error) {
+	empty := TaskPage{Tasks: []models.Task{}}
+	after, err := query.after()
+	if err != nil {
+		return empty, err
+	}
+
+	b := newQueryBuilder("scheduler")
+	b.and("deleted_at <> ''")
+	var total int
+	if err := b.get(db, &total, b.count()); err != nil {
+		return empty, err
+	}
+	if after.ID > 0 {
+		b.and("(deleted_at, id) < (?, ?)", after.Deleted, after.ID)
+	}
+
+	tasks := []models.Task{}
+	err = b.selectAll(db, &tasks, b.selectRows(taskColumns, "deleted_at DESC, id DESC", query.limit()+1, 0))
+	if err != nil {
+		return empty, err
+	}
+	return query.newPage(tasks, total), nil
+}
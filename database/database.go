@@ -2,91 +2,126 @@
 package database
 
 import (
-	"database/sql"
 	"errors"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 
+	"github.com/FausT-VX/todo-list-server/database/migrations"
 	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/service/search"
 	"github.com/FausT-VX/todo-list-server/settings"
 	"github.com/jmoiron/sqlx"
 )
 
-type TasksStore struct {
-	db *sqlx.DB
+// TaskRepository - хранилище задач
+type TaskRepository interface {
+	GetTaskByID(id int) (models.Task, error)
+	GetTasks(query TaskQuery) (TaskPage, error)
+	InsertTask(task models.Task) (int64, error)
+	UpdateTask(task models.Task) error
+	DeleteTaskByID(id int) error
 }
 
-func NewTasksStore(db *sqlx.DB) TasksStore {
-	return TasksStore{db: db}
+// HistoryRepository - хранилище истории повторений задач, записи добавляются методом CompleteTask
+type HistoryRepository interface {
+	GetHistory(taskID int, status string) ([]models.HistoryEntry, error)
 }
 
-// параметры для запросов
-type params struct {
-	Date   string `db:"date"`
-	Search string `db:"search"`
-	Limit  int    `db:"limit"`
+// TrashRepository - корзина задач, удаленных методом DeleteTaskByID
+type TrashRepository interface {
+	GetTrash(query TrashQuery) (TaskPage, error)
+	RestoreTask(id int) error
+	PurgeTrash(before string) (int64, error)
 }
 
-var info = log.New(os.Stdout, "todo-server INF: ", log.Ldate|log.Ltime)
+// CompletionRepository - хранилище истории выполнения задач
+type CompletionRepository interface {
+	// CompleteTask в одной транзакции записывает выполнение задачи completion и пропущенные повторения missed
+	// и переносит задачу на следующее повторение next, а если next = nil - отмечает задачу выполненной
+	CompleteTask(completion models.Completion, missed []models.HistoryEntry, next *models.Task) error
+	GetCompletions(taskID int, from string, to string) ([]models.Completion, error)
+}
 
-// CreateDB - создает базу данных по указанному пути dbPath
-func CreateDB(dbPath string) error {
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		log.Printf("func CreateDB. Error: %v", err)
-		return err
-	}
-	defer db.Close()
-
-	// Создание таблицы scheduler и индекса по полю date
-	queryCreate := `
-	CREATE TABLE IF NOT EXISTS scheduler (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		date CHAR(8) NOT NULL DEFAULT "",
-		title VARCHAR(128) NOT NULL DEFAULT "",
-		comment VARCHAR(1000) NOT NULL DEFAULT "",
-		repeat VARCHAR(128) NOT NULL DEFAULT ""
-	);     
-	CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler (date);
-	`
-
-	_, err = db.Exec(queryCreate)
-	if err != nil {
-		log.Printf("func CreateDB. Error creating table: %v", err)
-		return err
-	}
+// RevisionRepository - история изменений задач методами InsertTask, UpdateTask, DeleteTaskByID и RestoreTask
+type RevisionRepository interface {
+	GetRevisions(taskID int) ([]models.Revision, error)
+	RevertTask(taskID int, revisionID int) error
+}
 
-	return nil
+// Ошибки поиска задачи и ревизии: задачи нет, она находится в корзине (или вне её) или уже выполнена,
+// ревизии нет в истории изменений задачи
+var (
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// ErrTaskChanged - задача изменена другим запросом после того, как была получена для выполнения
+var ErrTaskChanged = errors.New("task has been changed by another request, retry")
+
+// Repository - хранилище задач, истории их повторений, выполнения и изменений и корзины
+type Repository interface {
+	TaskRepository
+	HistoryRepository
+	CompletionRepository
+	TrashRepository
+	RevisionRepository
+	// WithActor возвращает хранилище с теми же данными, записывающее изменения задач от имени пользователя actor
+	WithActor(actor string) Repository
 }
 
-// ConnectDB создает подключение к базе данных по указанному пути dbPath
-func ConnectDB(dbPath string) (*sqlx.DB, error) {
-	// если dbPath не существует, то создаём базу данных по указанному пути dbPath
+// TasksStore - хранилище задач в базе данных SQLite
+type TasksStore struct {
+	db    *sqlx.DB
+	actor string // пользователь, от имени которого записываются изменения задач
+}
+
+var _ Repository = TasksStore{}
+
+func NewTasksStore(db *sqlx.DB) TasksStore {
+	return TasksStore{db: db}
+}
+
+// taskColumns - список столбцов таблицы scheduler, соответствующих полям models.Task
+const taskColumns = "id, date, time, title, comment, repeat, repeat_count, repeat_until, repeat_from, overdue, tz, exceptions, deleted_at, completed_at"
+
+var info = log.New(os.Stdout, "todo-server INF: ", log.Ldate|log.Ltime)
+
+// DBFile возвращает путь к файлу базы данных: из переменной окружения TODO_DBFILE,
+// а если она не задана - путь dbPath относительно рабочего каталога
+func DBFile(dbPath string) (string, error) {
 	dbFile := settings.EnvDBFile
 	dbFile = strings.TrimPrefix(dbFile, ".")
 	if dbFile == "" {
 		appPath, err := os.Getwd()
 		if err != nil {
-			log.Fatalf("func ConnectDB. Error: %v", err)
+			return "", err
 		}
 		dbFile = filepath.Join(appPath, dbPath)
 	}
-	_, err := os.Stat(dbFile)
+	return dbFile, nil
+}
 
-	var install bool
+// OpenDB создает подключение к базе данных по указанному пути dbPath без обновления её структуры
+func OpenDB(dbPath string) (*sqlx.DB, error) {
+	dbFile, err := DBFile(dbPath)
 	if err != nil {
-		install = true
+		return nil, err
 	}
-	// если install равен true, после открытия БД требуется выполнить
-	// sql-запрос с CREATE TABLE и CREATE INDEX
-	if install {
-		if CreateDB(dbFile) != nil {
-			return nil, err
-		}
-		info.Println("Database has been successfully created")
+	return sqlx.Connect("sqlite", dbFile)
+}
+
+// ConnectDB создает подключение к базе данных по указанному пути dbPath.
+// Если база данных не существует, она создается; к существующей базе данных применяются новые миграции
+func ConnectDB(dbPath string) (*sqlx.DB, error) {
+	dbFile, err := DBFile(dbPath)
+	if err != nil {
+		log.Fatalf("func ConnectDB. Error: %v", err)
+	}
+	if _, err := os.Stat(dbFile); err != nil {
+		info.Println("Database will be created")
 	} else {
 		info.Println("Database already exists")
 	}
@@ -95,109 +130,161 @@ func ConnectDB(dbPath string) (*sqlx.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	//defer DB.Close()
+	applied, err := migrations.Up(db, false)
+	for _, migration := range applied {
+		info.Printf("Migration %04d_%s has been applied", migration.Version, migration.Name)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 	return db, nil
 }
 
 // GetTaskByID - получение задачи по id
 func (s TasksStore) GetTaskByID(id int) (models.Task, error) {
 	task := models.Task{}
-	err := s.db.Get(&task, "SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?", id)
+	err := s.db.Get(&task, "SELECT "+taskColumns+" FROM scheduler WHERE id = ? AND deleted_at = '' AND completed_at = ''", id)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
-			err = errors.New("task not found")
+			err = ErrTaskNotFound
 		}
 		return models.Task{}, err
 	}
 	return task, nil
 }
 
-// DeleteTaskByID - удаление задачи по id
+// DeleteTaskByID - удаление задачи по id в корзину
 func (s TasksStore) DeleteTaskByID(id int) error {
-	result, err := s.db.Exec("DELETE FROM scheduler WHERE id = ?", id)
-	if err != nil {
-		return err
-	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return errors.New("task not found")
-	}
-	return nil
-}
-
-// GetTasks - получение всех задач если search = "";
-// если search равен строке в формате "02.01.2006", задачи на указанную дату;
-// иначе ищет задачи содержащие подстроку search в полях title и comment с учетом регистра;
-// во всех случая учитывается ограничение на количество возвращаемых строк database.RowsLimit
-func (s TasksStore) GetTasks(search string) ([]models.Task, error) {
-	// хотел реализовать бе учета регистра но обнаружил, а потом и нагуглил, что sqlite не поддерживает LOWER() для кириллицы
-	var args params
-	query := ""
-
-	// в зависимости от наличия и значения параметра search задаем соответствующий запрос и определяем его параметры
-	if search != "" {
-		if date, err := time.Parse("02.01.2006", search); err == nil {
-			query = "SELECT id, date, title, comment, repeat FROM scheduler WHERE date = :date LIMIT :limit"
-			args = params{Date: date.Format(settings.DateFormat), Limit: settings.Limit50}
-		} else {
-			query = "SELECT id, date, title, comment, repeat FROM scheduler WHERE title LIKE :search OR comment LIKE :search ORDER BY date LIMIT :limit"
-			args = params{Search: "%" + search + "%", Limit: settings.Limit50}
+	return deleteTask(s.db, s.actor, id)
+}
+
+// GetTasks - получение страницы списка задач по условиям query: всех задач если query.Search = "";
+// если query.Search равен строке в формате "02.01.2006", задач на указанную дату;
+// иначе полнотекстовый поиск задач, в полях title и comment которых есть слова, начинающиеся с каждого из слов поиска,
+// без учета регистра для любых алфавитов. Найденные задачи по умолчанию упорядочены по релевантности и содержат
+// фрагмент текста с выделенными совпадениями, остальные списки - по дате, времени и id
+func (s TasksStore) GetTasks(query TaskQuery) (TaskPage, error) {
+	return getTasks(s.db, sqliteTasks, query)
+}
+
+// sqliteTasks - запросы списка задач в SQLite: полнотекстовый поиск по индексу FTS5 scheduler_fts
+var sqliteTasks = taskDialect{
+	fullText: func(b *queryBuilder, positive []search.Term, negative []search.Term, in string) (string, string) {
+		for _, term := range negative {
+			b.and("scheduler.id NOT IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)",
+				ftsQuery([]search.Term{term}, in))
 		}
-	} else {
-		query = "SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date LIMIT :limit"
-		args = params{Limit: settings.Limit50}
-	}
+		if len(positive) == 0 {
+			return "", ""
+		}
+		column := map[string]int{"": -1, SearchTitle: 0, SearchComment: 1}[in]
+		b.join("JOIN (SELECT rowid, rank, snippet(scheduler_fts, "+strconv.Itoa(column)+", '"+matchStart+"', '"+matchEnd+
+			"', '…', "+strconv.Itoa(snippetWords)+") AS snippet FROM scheduler_fts WHERE scheduler_fts MATCH ?) AS fts "+
+			"ON fts.rowid = scheduler.id", ftsQuery(positive, in))
+		return "fts.snippet", "fts.rank"
+	},
+}
 
-	tasks := []models.Task{}
-	task := models.Task{}
-	// Выполняем подготовленный запрос
-	rows, err := s.db.NamedQuery(query, args)
-	if err != nil {
-		return []models.Task{}, err
-	}
-	for rows.Next() {
-		err = rows.StructScan(&task)
+// UpdateTask - обновление задачи по id
+func (s TasksStore) UpdateTask(task models.Task) error {
+	return updateTask(s.db, s.actor, models.RevisionUpdate, task)
+}
+
+// InsertTask - добавление задачи, возвращает id добавленной задачи
+func (s TasksStore) InsertTask(task models.Task) (int64, error) {
+	return insertTask(s.db, s.actor, func(tx *sqlx.Tx) (int64, error) {
+		result, err := tx.NamedExec(`INSERT INTO scheduler (date, time, title, comment, repeat, repeat_count, repeat_until, repeat_from, overdue, tz, exceptions)
+			VALUES (:date, :time, :title, :comment, :repeat, :repeat_count, :repeat_until, :repeat_from, :overdue, :tz, :exceptions)`, &task)
 		if err != nil {
-			return []models.Task{}, err
+			return 0, err
 		}
-		tasks = append(tasks, task)
+		// Получаем ID последней вставленной записи
+		return result.LastInsertId()
+	})
+}
+
+// GetHistory - получение последних записей истории повторений со статусом status,
+// для задачи с идентификатором taskID либо, если taskID равен 0, для всех задач
+func (s TasksStore) GetHistory(taskID int, status string) ([]models.HistoryEntry, error) {
+	query := "SELECT id, task_id, date, time, title, status FROM history WHERE status = ?"
+	args := []any{status}
+	if taskID != 0 {
+		query += " AND task_id = ?"
+		args = append(args, taskID)
 	}
-	if err = rows.Err(); err != nil {
-		return []models.Task{}, err
+	query += " ORDER BY date DESC, time DESC, id DESC LIMIT ?"
+	args = append(args, settings.Limit50)
+
+	entries := []models.HistoryEntry{}
+	if err := s.db.Select(&entries, query, args...); err != nil {
+		return []models.HistoryEntry{}, err
 	}
+	return entries, nil
+}
 
-	return tasks, nil
+// CompleteTask - запись выполнения задачи и её перенос на следующее повторение next либо, если next = nil,
+// отметка о выполнении в одной транзакции
+func (s TasksStore) CompleteTask(completion models.Completion, missed []models.HistoryEntry, next *models.Task) error {
+	return completeTask(s.db, s.actor, completion, missed, next)
 }
 
-// UpdateTask - обновление задачи по id
-func (s TasksStore) UpdateTask(task models.Task) error {
-	result, err := s.db.NamedExec("UPDATE scheduler SET date = :date, title = :title, comment = :comment, repeat = :repeat WHERE id = :id", &task)
-	if err != nil {
-		return err
+// GetCompletions - получение истории выполнения задач, начиная с последних выполненных.
+// Возвращает записи для задачи с идентификатором taskID либо, если taskID равен 0, для всех задач,
+// выполненные с даты from по дату to включительно (в формате 20060102, "" - без ограничения)
+func (s TasksStore) GetCompletions(taskID int, from string, to string) ([]models.Completion, error) {
+	return getCompletions(s.db, taskID, from, to)
+}
+
+// getCompletions возвращает историю выполнения задач базы данных db по условиям GetCompletions
+func getCompletions(db *sqlx.DB, taskID int, from string, to string) ([]models.Completion, error) {
+	b := newQueryBuilder("completions")
+	if taskID != 0 {
+		b.and("task_id = ?", taskID)
 	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+	// время выполнения начинается с даты, поэтому сравнивается с датами интервала как строка
+	if from != "" {
+		b.and("completed_at >= ?", from)
 	}
-	if rowsAffected == 0 {
-		return errors.New("task not found")
+	if to != "" {
+		b.and("completed_at <= ?", to+" 23:59")
 	}
-	return nil
+	completions := []models.Completion{}
+	query := b.selectRows("id, task_id, date, time, completed_at, title, comment, repeat", "completed_at DESC, id DESC", settings.MaxLimit, 0)
+	if err := b.selectAll(db, &completions, query); err != nil {
+		return []models.Completion{}, err
+	}
+	return completions, nil
 }
 
-// DeleteTask - удаление задачи по id
-func (s TasksStore) InsertTask(task models.Task) (lastInsertId int64, err error) {
-	resultDB, err := s.db.NamedExec("INSERT INTO scheduler (date, title, comment, repeat) VALUES (:date, :title, :comment, :repeat)", &task)
-	if err != nil {
-		return 0, err
-	}
-	// Получаем ID последней вставленной записи
-	lastInsertId, err = resultDB.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-	return lastInsertId, nil
+// GetTrash - получение страницы задач из корзины по условиям query, начиная с удаленных последними
+func (s TasksStore) GetTrash(query TrashQuery) (TaskPage, error) {
+	return getTrash(s.db, query)
+}
+
+// RestoreTask - восстановление задачи из корзины по id
+func (s TasksStore) RestoreTask(id int) error {
+	return restoreTask(s.db, s.actor, id)
+}
+
+// PurgeTrash - окончательное удаление задач, помещенных в корзину ранее before (в формате "20060102 15:04" UTC),
+// вместе с их историей повторений и выполнения; возвращает количество удаленных задач
+func (s TasksStore) PurgeTrash(before string) (int64, error) {
+	return purgeTrash(s.db, s.actor, before)
+}
+
+// GetRevisions - получение истории изменений задачи по taskID, начиная с последних изменений
+func (s TasksStore) GetRevisions(taskID int) ([]models.Revision, error) {
+	return getRevisions(s.db, taskID)
+}
+
+// RevertTask - возврат задаче по taskID значений после изменения ревизией revisionID
+func (s TasksStore) RevertTask(taskID int, revisionID int) error {
+	return revertTask(s.db, s.actor, taskID, revisionID)
+}
+
+// WithActor возвращает хранилище, записывающее изменения задач от имени пользователя actor
+func (s TasksStore) WithActor(actor string) Repository {
+	s.actor = actor
+	return s
 }
@@ -0,0 +1,422 @@
+package database
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/service/search"
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// MemoryStore - потокобезопасное хранилище задач, истории их повторений и выполнения и корзины в памяти.
+// Повторяет поведение TasksStore и используется в тестах обработчиков без базы данных
+type MemoryStore struct {
+	*memoryData
+	actor string // пользователь, от имени которого записываются изменения задач
+}
+
+// memoryData - данные хранилища в памяти, общие для хранилищ, полученных методом WithActor
+type memoryData struct {
+	mu        sync.RWMutex
+	tasks     map[int]models.Task
+	history   []models.HistoryEntry
+	done      []models.Completion
+	revisions []models.Revision
+	lastID    int // последний выданный id задачи
+
+	// последние выданные id записей истории повторений и выполнения: удаленные записи не освобождают id
+	lastHistoryID    int
+	lastCompletionID int
+}
+
+var _ Repository = (*MemoryStore)(nil)
+
+// NewMemoryStore создает пустое хранилище задач в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{memoryData: &memoryData{tasks: map[int]models.Task{}}}
+}
+
+// GetTaskByID - получение задачи по id
+func (s *MemoryStore) GetTaskByID(id int) (models.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, ok := s.tasks[id]
+	if !ok || !active(task) {
+		return models.Task{}, ErrTaskNotFound
+	}
+	return task, nil
+}
+
+// DeleteTaskByID - удаление задачи по id в корзину
+func (s *MemoryStore) DeleteTaskByID(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok || !active(task) {
+		return ErrTaskNotFound
+	}
+	old := task
+	task.DeletedAt = utcNow()
+	s.tasks[id] = task
+	return s.addRevision(models.RevisionDelete, &old, task)
+}
+
+// GetTasks - получение страницы списка задач по условиям, аналогичным TasksStore.GetTasks.
+// Релевантность найденной задачи - количество совпавших слов; фрагментом с выделенными совпадениями
+// считается всё поле, в котором больше совпадений
+func (s *MemoryStore) GetTasks(query TaskQuery) (TaskPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	empty := TaskPage{Tasks: []models.Task{}}
+	if err := query.Validate(); err != nil {
+		return empty, err
+	}
+	after, err := query.after()
+	if err != nil {
+		return empty, err
+	}
+	parsed, searching := query.searchQuery()
+	if searching && len(parsed.Terms) == 0 {
+		return empty, nil
+	}
+	positive := parsed.TextTerms(false)
+	day, clock := query.now()
+
+	tasks := []models.Task{}
+	hits := map[string]int{} // релевантность найденных задач по id
+	for _, task := range s.tasks {
+		if !active(task) {
+			continue
+		}
+		if date, ok := query.date(); ok && task.Date != date ||
+			query.From != "" && task.Date < query.From || query.To != "" && task.Date > query.To ||
+			query.Repeating != nil && *query.Repeating != (task.Repeat != "") {
+			continue
+		}
+		overdue := task.Date < day || task.Date == day && task.Time != "" && task.Time < clock
+		if query.Overdue != nil && *query.Overdue != overdue {
+			continue
+		}
+		words := newTaskWords(task)
+		if slices.ContainsFunc(parsed.Terms, func(term search.Term) bool {
+			return words.match(task, term, query.SearchIn) == term.Negate
+		}) {
+			continue
+		}
+		if len(positive) > 0 {
+			var title, comment textMatch
+			if query.SearchIn != SearchComment {
+				title = highlight(task.Title, wordMatcher(positive, SearchTitle, query.SearchIn))
+			}
+			if query.SearchIn != SearchTitle {
+				comment = highlight(task.Comment, wordMatcher(positive, SearchComment, query.SearchIn))
+			}
+			task.Snippet = snippetHTML(title.text)
+			if query.SearchIn == SearchComment || comment.hits > title.hits {
+				task.Snippet = snippetHTML(comment.text)
+			}
+			hits[task.ID] = title.hits + comment.hits
+		}
+		tasks = append(tasks, task)
+	}
+
+	// задачи упорядочиваются по ключу упорядочения, а при его отсутствии - по релевантности и дате
+	keys := query.sortKeys()
+	byRelevance := keys == nil
+	if byRelevance {
+		keys = []string{"date", "time", "id"}
+	}
+	compare := func(a, b models.Task) int {
+		result := 0
+		for _, key := range keys {
+			switch key {
+			case "date":
+				result = strings.Compare(a.Date, b.Date)
+			case "time":
+				result = strings.Compare(a.Time, b.Time)
+			case "title":
+				result = strings.Compare(a.Title, b.Title)
+			case "id":
+				result = compareIDs(a.ID, b.ID)
+			}
+			if result != 0 {
+				break
+			}
+		}
+		if query.Desc {
+			return -result
+		}
+		return result
+	}
+	if byRelevance {
+		slices.SortFunc(tasks, func(a, b models.Task) int { return cmp.Or(cmp.Compare(hits[b.ID], hits[a.ID]), compare(a, b)) })
+	} else {
+		slices.SortFunc(tasks, compare)
+	}
+
+	total := len(tasks)
+	if after.ID > 0 {
+		last := models.Task{ID: strconv.Itoa(after.ID), Date: after.Date, Time: after.Time, Title: after.Title}
+		tasks = slices.DeleteFunc(tasks, func(task models.Task) bool { return compare(task, last) <= 0 })
+	}
+	tasks = tasks[min(after.Offset, len(tasks)):]
+	tasks = tasks[:min(query.limit()+1, len(tasks))]
+	return query.newPage(tasks, after, total), nil
+}
+
+// UpdateTask - обновление задачи по id
+func (s *MemoryStore) UpdateTask(task models.Task) error {
+	return s.update(task, models.RevisionUpdate)
+}
+
+// update записывает значения задачи task и ревизию с действием action
+func (s *MemoryStore) update(task models.Task, action string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(task, action)
+}
+
+// write заменяет задачу task и записывает ревизию с действием action; вызывается при заблокированном хранилище
+func (s *MemoryStore) write(task models.Task, action string) error {
+	id, err := strconv.Atoi(strings.TrimSpace(task.ID))
+	old, ok := s.tasks[id]
+	if err != nil || !ok || !active(old) {
+		return ErrTaskNotFound
+	}
+	task.ID, task.RepeatText, task.RRule, task.Snippet, task.DeletedAt, task.CompletedAt = strconv.Itoa(id), "", "", "", "", ""
+	s.tasks[id] = task
+	return s.addRevision(action, &old, task)
+}
+
+// InsertTask - добавление задачи, возвращает id добавленной задачи
+func (s *MemoryStore) InsertTask(task models.Task) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	task.ID, task.RepeatText, task.RRule, task.Snippet, task.DeletedAt, task.CompletedAt = strconv.Itoa(s.lastID), "", "", "", "", ""
+	s.tasks[s.lastID] = task
+	return int64(s.lastID), s.addRevision(models.RevisionInsert, nil, task)
+}
+
+// GetHistory - получение последних записей истории повторений со статусом status,
+// для задачи с идентификатором taskID либо, если taskID равен 0, для всех задач
+func (s *MemoryStore) GetHistory(taskID int, status string) ([]models.HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []models.HistoryEntry{}
+	for _, entry := range s.history {
+		if entry.Status == status && (taskID == 0 || entry.TaskID == strconv.Itoa(taskID)) {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b models.HistoryEntry) int {
+		return cmp.Or(strings.Compare(b.Date, a.Date), strings.Compare(b.Time, a.Time), compareIDs(b.ID, a.ID))
+	})
+	if len(entries) > settings.Limit50 {
+		entries = entries[:settings.Limit50]
+	}
+	return entries, nil
+}
+
+// CompleteTask - запись выполнения задачи и её изменение, аналогично TasksStore.CompleteTask
+func (s *MemoryStore) CompleteTask(completion models.Completion, missed []models.HistoryEntry, next *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := strconv.Atoi(strings.TrimSpace(completion.TaskID))
+	old, ok := s.tasks[id]
+	if err != nil || !ok || !active(old) {
+		return ErrTaskNotFound
+	}
+	if old.Date != completion.Date || old.Time != completion.Time {
+		return ErrTaskChanged
+	}
+	task := old
+	if next != nil {
+		task = *next
+		task.ID, task.RepeatText, task.RRule, task.Snippet, task.DeletedAt, task.CompletedAt = old.ID, "", "", "", "", ""
+	} else {
+		task.CompletedAt = completion.CompletedAt
+	}
+	// ревизия формируется до изменения данных, чтобы при ошибке хранилище осталось прежним
+	revision, err := newRevision(models.RevisionDone, s.actor, &old, task)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range missed {
+		s.lastHistoryID++
+		entry.ID = strconv.Itoa(s.lastHistoryID)
+		s.history = append(s.history, entry)
+	}
+	s.lastCompletionID++
+	completion.ID = strconv.Itoa(s.lastCompletionID)
+	s.done = append(s.done, completion)
+	s.tasks[id] = task
+	revision.ID = strconv.Itoa(len(s.revisions) + 1)
+	s.revisions = append(s.revisions, revision)
+	return nil
+}
+
+// GetCompletions - получение истории выполнения задач по условиям, аналогичным TasksStore.GetCompletions
+func (s *MemoryStore) GetCompletions(taskID int, from string, to string) ([]models.Completion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	completions := []models.Completion{}
+	for _, completion := range s.done {
+		date := completion.CompletedAt[:min(len(completion.CompletedAt), len(settings.DateFormat))]
+		if (taskID == 0 || completion.TaskID == strconv.Itoa(taskID)) &&
+			(from == "" || date >= from) && (to == "" || date <= to) {
+			completions = append(completions, completion)
+		}
+	}
+	slices.SortFunc(completions, func(a, b models.Completion) int {
+		return cmp.Or(strings.Compare(b.CompletedAt, a.CompletedAt), compareIDs(b.ID, a.ID))
+	})
+	if len(completions) > settings.MaxLimit {
+		completions = completions[:settings.MaxLimit]
+	}
+	return completions, nil
+}
+
+// GetTrash - получение страницы задач из корзины по условиям query, начиная с удаленных последними
+func (s *MemoryStore) GetTrash(query TrashQuery) (TaskPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	after, err := query.after()
+	if err != nil {
+		return TaskPage{Tasks: []models.Task{}}, err
+	}
+	tasks := []models.Task{}
+	for _, task := range s.tasks {
+		if task.DeletedAt != "" {
+			tasks = append(tasks, task)
+		}
+	}
+	compare := func(a, b models.Task) int {
+		return cmp.Or(strings.Compare(b.DeletedAt, a.DeletedAt), compareIDs(b.ID, a.ID))
+	}
+	slices.SortFunc(tasks, compare)
+
+	total := len(tasks)
+	if after.ID > 0 {
+		last := models.Task{ID: strconv.Itoa(after.ID), DeletedAt: after.Deleted}
+		tasks = slices.DeleteFunc(tasks, func(task models.Task) bool { return compare(task, last) <= 0 })
+	}
+	tasks = tasks[:min(query.limit()+1, len(tasks))]
+	return query.newPage(tasks, total), nil
+}
+
+// RestoreTask - восстановление задачи из корзины по id
+func (s *MemoryStore) RestoreTask(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok || task.DeletedAt == "" {
+		return ErrTaskNotFound
+	}
+	old := task
+	task.DeletedAt = ""
+	s.tasks[id] = task
+	return s.addRevision(models.RevisionRestore, &old, task)
+}
+
+// PurgeTrash - окончательное удаление задач, помещенных в корзину ранее before, вместе с их историей повторений
+// и выполнения; возвращает количество удаленных задач
+func (s *MemoryStore) PurgeTrash(before string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := map[string]bool{}
+	for _, id := range slices.Sorted(maps.Keys(s.tasks)) {
+		task := s.tasks[id]
+		if task.DeletedAt == "" || task.DeletedAt >= before {
+			continue
+		}
+		delete(s.tasks, id)
+		purged[task.ID] = true
+		if err := s.addRevision(models.RevisionPurge, &task, models.Task{ID: task.ID}); err != nil {
+			return 0, err
+		}
+	}
+	s.history = slices.DeleteFunc(s.history, func(entry models.HistoryEntry) bool { return purged[entry.TaskID] })
+	s.done = slices.DeleteFunc(s.done, func(completion models.Completion) bool { return purged[completion.TaskID] })
+	return int64(len(purged)), nil
+}
+
+// GetRevisions - получение истории изменений задачи по taskID, начиная с последних изменений
+func (s *MemoryStore) GetRevisions(taskID int) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := []models.Revision{}
+	for _, revision := range slices.Backward(s.revisions) {
+		if revision.TaskID == strconv.Itoa(taskID) && len(revisions) < settings.MaxLimit {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+// RevertTask - возврат задаче по taskID значений после изменения ревизией revisionID
+func (s *MemoryStore) RevertTask(taskID int, revisionID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task, ok := s.tasks[taskID]; !ok || !active(task) {
+		return ErrTaskNotFound
+	}
+	index := slices.IndexFunc(s.revisions, func(revision models.Revision) bool {
+		return revision.ID == strconv.Itoa(revisionID) && revision.TaskID == strconv.Itoa(taskID)
+	})
+	if index < 0 {
+		return ErrRevisionNotFound
+	}
+	task, err := revisionTask(s.revisions[index])
+	if err != nil {
+		return err
+	}
+	task.ID = strconv.Itoa(taskID)
+	return s.write(task, models.RevisionRevert)
+}
+
+// WithActor возвращает хранилище с теми же данными, записывающее изменения задач от имени пользователя actor
+func (s *MemoryStore) WithActor(actor string) Repository {
+	return &MemoryStore{memoryData: s.memoryData, actor: actor}
+}
+
+// addRevision записывает ревизию с действием action, изменившую задачу old на task; вызывается при заблокированном хранилище
+func (s *MemoryStore) addRevision(action string, old *models.Task, task models.Task) error {
+	revision, err := newRevision(action, s.actor, old, task)
+	if err != nil {
+		return err
+	}
+	revision.ID = strconv.Itoa(len(s.revisions) + 1)
+	s.revisions = append(s.revisions, revision)
+	return nil
+}
+
+// active определяет, что задача task не удалена в корзину и не выполнена
+func active(task models.Task) bool {
+	return task.DeletedAt == "" && task.CompletedAt == ""
+}
+
+// compareIDs сравнивает числовые идентификаторы a и b, заданные строками
+func compareIDs(a string, b string) int {
+	idA, _ := strconv.Atoi(a)
+	idB, _ := strconv.Atoi(b)
+	return cmp.Compare(idA, idB)
+}
@@ -0,0 +1,291 @@
+// Package migrations обновляет структуру базы данных упорядоченными SQL-миграциями,
+// встроенными в исполняемый файл. Применённые миграции записываются в таблицу schema_version.
+// Миграции каждой СУБД (диалекта) хранятся в отдельном каталоге, номера версий в каталогах совпадают
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// files - SQL-миграции в файлах вида <диалект>/0001_name.sql, применяются в порядке номеров версий.
+// Файл миграции выполняется целиком как один SQL-скрипт, строки комментариев начинаются с "--".
+// Миграция 1 создает исходную структуру базы данных, созданной до появления миграций
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// Диалекты SQL - названия каталогов миграций внутри files
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// Migration - миграция структуры базы данных
+type Migration struct {
+	Version int    // номер версии структуры БД после применения миграции
+	Name    string // название миграции из имени файла
+	SQL     string // SQL-скрипт миграции
+}
+
+// Status - состояние миграции в базе данных
+type Status struct {
+	Migration
+	AppliedAt string // время применения миграции в формате 2006-01-02 15:04:05, "" - миграция не применена
+}
+
+// createVersionTable - таблица применённых миграций
+const createVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(128) NOT NULL DEFAULT '',
+		applied_at CHAR(19) NOT NULL DEFAULT ''
+	)`
+
+// versionTableExists - запросы проверки существования таблицы schema_version по диалектам
+var versionTableExists = map[string]string{
+	SQLite:   "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'",
+	Postgres: "SELECT to_regclass('schema_version') IS NOT NULL",
+}
+
+// legacyColumns - столбцы таблицы scheduler, которые до появления миграций добавлялись в базу данных SQLite
+// при подключении к ней, по версиям миграций, добавляющих их. Столбцы добавлялись в порядке версий,
+// поэтому набор существующих столбцов определяет версию структуры такой базы данных
+var legacyColumns = []struct {
+	version int
+	columns []string
+}{
+	{2, []string{"repeat_count", "repeat_until"}},
+	{3, []string{"repeat_from"}},
+	{4, []string{"time"}},
+	{5, []string{"tz"}},
+	{6, []string{"exceptions"}},
+	{7, []string{"overdue"}},
+}
+
+// Dialect возвращает диалект SQL базы данных db по имени её драйвера
+func Dialect(db *sqlx.DB) (string, error) {
+	switch name := db.DriverName(); name {
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	case "postgres", "pgx":
+		return Postgres, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q", name)
+	}
+}
+
+// List возвращает все миграции диалекта dialect в порядке версий
+func List(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	for _, entry := range entries {
+		version, name, found := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		number, err := strconv.Atoi(version)
+		if !found || err != nil || number < 1 {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		data, err := files.ReadFile(path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: number, Name: name, SQL: string(data)})
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest возвращает версию структуры БД диалекта dialect после применения всех миграций
+func Latest(dialect string) (int, error) {
+	migrations, err := List(dialect)
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Current возвращает текущую версию структуры базы данных db; 0 - миграции не применялись
+func Current(db *sqlx.DB) (int, error) {
+	dialect, err := Dialect(db)
+	if err != nil {
+		return 0, err
+	}
+	var exists bool
+	err = db.Get(&exists, versionTableExists[dialect])
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err = db.Get(&version, "SELECT COALESCE(MAX(version), 0) FROM schema_version")
+	return version, err
+}
+
+// Statuses возвращает все миграции с признаком их применения к базе данных db
+func Statuses(db *sqlx.DB) ([]Status, error) {
+	dialect, err := Dialect(db)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := List(dialect)
+	if err != nil {
+		return nil, err
+	}
+	var applied []struct {
+		Version   int    `db:"version"`
+		AppliedAt string `db:"applied_at"`
+	}
+	if current, err := Current(db); err != nil {
+		return nil, err
+	} else if current > 0 {
+		if err := db.Select(&applied, "SELECT version, applied_at FROM schema_version"); err != nil {
+			return nil, err
+		}
+	}
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Migration: migration}
+		for _, v := range applied {
+			if v.Version == migration.Version {
+				status.AppliedAt = v.AppliedAt
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up применяет к базе данных db миграции с версиями больше текущей и возвращает применённые миграции.
+// Каждая миграция применяется в отдельной транзакции. При dryRun все миграции выполняются в одной
+// транзакции, которая затем откатывается: проверяется их применимость, а база данных не изменяется.
+//
+// Версия структуры базы данных SQLite, созданной до появления миграций, определяется один раз по её столбцам
+// (см. legacyColumns): миграции до этой версии записываются в schema_version как применённые без выполнения
+func Up(db *sqlx.DB, dryRun bool) ([]Migration, error) {
+	dialect, err := Dialect(db)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := List(dialect)
+	if err != nil {
+		return nil, err
+	}
+	current, err := Current(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *sqlx.Tx // транзакция текущей миграции либо, при dryRun, всех миграций
+	defer func() {
+		if tx != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if current == 0 && dialect == SQLite {
+		legacy, err := legacyVersion(db)
+		if err != nil {
+			return nil, err
+		}
+		if legacy > 0 {
+			if tx, err = db.Beginx(); err != nil {
+				return nil, err
+			}
+			for _, migration := range migrations {
+				if migration.Version > legacy {
+					break
+				}
+				if err := record(tx, migration); err != nil {
+					return nil, err
+				}
+			}
+			if !dryRun {
+				err, tx = tx.Commit(), nil
+				if err != nil {
+					return nil, err
+				}
+			}
+			current = legacy
+		}
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if tx == nil {
+			if tx, err = db.Beginx(); err != nil {
+				return applied, err
+			}
+		}
+		if err := apply(tx, migration); err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+		if !dryRun {
+			err, tx = tx.Commit(), nil
+			if err != nil {
+				return applied, err
+			}
+		}
+	}
+	return applied, nil
+}
+
+// legacyVersion возвращает версию структуры базы данных SQLite db, созданной до появления миграций:
+// 0 - таблицы scheduler нет, 1 - исходная структура, иначе последняя версия, все столбцы которой и предыдущих
+// версий существуют
+func legacyVersion(db *sqlx.DB) (int, error) {
+	var exists bool
+	err := db.Get(&exists, "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'scheduler'")
+	if err != nil || !exists {
+		return 0, err
+	}
+	var columns []string
+	if err := db.Select(&columns, "SELECT name FROM pragma_table_info('scheduler')"); err != nil {
+		return 0, err
+	}
+	version := 1
+	for _, added := range legacyColumns {
+		for _, column := range added.columns {
+			if !slices.Contains(columns, column) {
+				return version, nil
+			}
+		}
+		version = added.version
+	}
+	return version, nil
+}
+
+// apply выполняет скрипт миграции migration в транзакции tx и записывает её версию в schema_version
+func apply(tx *sqlx.Tx, migration Migration) error {
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return err
+	}
+	return record(tx, migration)
+}
+
+// record записывает в транзакции tx версию миграции migration в schema_version
+func record(tx *sqlx.Tx, migration Migration) error {
+	if _, err := tx.Exec(createVersionTable); err != nil {
+		return err
+	}
+	_, err := tx.Exec(tx.Rebind("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)"),
+		migration.Version, migration.Name, time.Now().Format(time.DateTime))
+	return err
+}
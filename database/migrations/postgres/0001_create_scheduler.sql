@@ -0,0 +1,9 @@
+-- Таблица задач и индекс по дате
+CREATE TABLE IF NOT EXISTS scheduler (
+	id BIGSERIAL PRIMARY KEY,
+	date VARCHAR(8) NOT NULL DEFAULT '',
+	title VARCHAR(128) NOT NULL DEFAULT '',
+	comment VARCHAR(1000) NOT NULL DEFAULT '',
+	repeat VARCHAR(128) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler (date);
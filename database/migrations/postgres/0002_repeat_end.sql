@@ -0,0 +1,3 @@
+-- Условия окончания повторений: количество повторений и дата окончания
+ALTER TABLE scheduler ADD COLUMN repeat_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scheduler ADD COLUMN repeat_until VARCHAR(8) NOT NULL DEFAULT '';
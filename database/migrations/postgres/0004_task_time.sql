@@ -0,0 +1,2 @@
+-- Время задачи в формате 15:04
+ALTER TABLE scheduler ADD COLUMN time VARCHAR(5) NOT NULL DEFAULT '';
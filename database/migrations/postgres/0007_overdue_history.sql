@@ -0,0 +1,11 @@
+-- Политика для просроченных повторений и история повторений задач
+ALTER TABLE scheduler ADD COLUMN overdue VARCHAR(16) NOT NULL DEFAULT 'skip';
+CREATE TABLE IF NOT EXISTS history (
+	id BIGSERIAL PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	date VARCHAR(8) NOT NULL DEFAULT '',
+	time VARCHAR(5) NOT NULL DEFAULT '',
+	title VARCHAR(128) NOT NULL DEFAULT '',
+	status VARCHAR(16) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS history_task_id ON history (task_id);
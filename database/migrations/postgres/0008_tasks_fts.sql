@@ -0,0 +1,3 @@
+-- Полнотекстовый поиск задач: индекс GIN по названию и комментарию задачи.
+-- Конфигурация simple приводит слова к нижнему регистру без морфологии, как токенизатор FTS5 unicode61 в SQLite
+CREATE INDEX IF NOT EXISTS scheduler_fts ON scheduler USING GIN (to_tsvector('simple', title || ' ' || comment));
@@ -0,0 +1,3 @@
+-- Корзина: удаленные задачи помечаются временем удаления в формате "20060102 15:04" UTC, "" - задача не удалена
+ALTER TABLE scheduler ADD COLUMN deleted_at VARCHAR(14) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS scheduler_deleted_at ON scheduler (deleted_at);
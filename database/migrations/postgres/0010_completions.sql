@@ -0,0 +1,13 @@
+-- История выполнения задач: копия задачи на момент выполнения, дата повторения по расписанию и время выполнения
+CREATE TABLE IF NOT EXISTS completions (
+	id BIGSERIAL PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	date VARCHAR(8) NOT NULL DEFAULT '',
+	time VARCHAR(5) NOT NULL DEFAULT '',
+	completed_at VARCHAR(14) NOT NULL DEFAULT '',
+	title VARCHAR(128) NOT NULL DEFAULT '',
+	comment VARCHAR(1000) NOT NULL DEFAULT '',
+	repeat VARCHAR(128) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS completions_completed_at ON completions (completed_at);
+CREATE INDEX IF NOT EXISTS completions_task_id ON completions (task_id);
@@ -0,0 +1,3 @@
+-- Поиск задач без удаления диакритических знаков, как в SQLite после этой миграции.
+-- Конфигурация simple не удаляет диакритические знаки, поэтому индекс scheduler_fts не изменяется
+COMMENT ON INDEX scheduler_fts IS 'полнотекстовый поиск по названию и комментарию задачи без удаления диакритических знаков';
@@ -0,0 +1,2 @@
+-- Режим отсчёта повторений: от запланированной даты или от даты выполнения
+ALTER TABLE scheduler ADD COLUMN repeat_from VARCHAR(16) NOT NULL DEFAULT "schedule";
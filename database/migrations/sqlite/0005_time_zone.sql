@@ -0,0 +1,2 @@
+-- Часовой пояс задачи
+ALTER TABLE scheduler ADD COLUMN tz VARCHAR(64) NOT NULL DEFAULT "";
@@ -0,0 +1,2 @@
+-- Исключения из серии повторений: пропущенные и перенесённые повторения
+ALTER TABLE scheduler ADD COLUMN exceptions VARCHAR(1000) NOT NULL DEFAULT "";
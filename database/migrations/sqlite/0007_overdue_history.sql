@@ -0,0 +1,11 @@
+-- Политика для просроченных повторений и история повторений задач
+ALTER TABLE scheduler ADD COLUMN overdue VARCHAR(16) NOT NULL DEFAULT "skip";
+CREATE TABLE IF NOT EXISTS history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	date CHAR(8) NOT NULL DEFAULT "",
+	time CHAR(5) NOT NULL DEFAULT "",
+	title VARCHAR(128) NOT NULL DEFAULT "",
+	status VARCHAR(16) NOT NULL DEFAULT ""
+);
+CREATE INDEX IF NOT EXISTS history_task_id ON history (task_id);
@@ -0,0 +1,18 @@
+-- Полнотекстовый поиск задач: индекс FTS5 по названию и комментарию задачи.
+-- Токенизатор unicode61 приводит к нижнему регистру буквы любых алфавитов, включая кириллицу.
+-- Индекс хранит только ссылки на строки scheduler и обновляется триггерами
+CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(
+	title, comment, content = 'scheduler', content_rowid = 'id', tokenize = 'unicode61'
+);
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_insert AFTER INSERT ON scheduler BEGIN
+	INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_delete AFTER DELETE ON scheduler BEGIN
+	INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+END;
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler BEGIN
+	INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+	INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+-- индексирование задач, добавленных до появления поиска
+INSERT INTO scheduler_fts (scheduler_fts) VALUES ('rebuild');
@@ -0,0 +1,11 @@
+-- История изменений задач: значения задачи до и после каждого добавления, изменения, удаления и восстановления
+CREATE TABLE IF NOT EXISTS revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	action VARCHAR(16) NOT NULL DEFAULT "",
+	changed_at VARCHAR(14) NOT NULL DEFAULT "",
+	actor VARCHAR(128) NOT NULL DEFAULT "",
+	old_task TEXT NOT NULL DEFAULT "",
+	new_task TEXT NOT NULL DEFAULT ""
+);
+CREATE INDEX IF NOT EXISTS revisions_task_id ON revisions (task_id);
@@ -0,0 +1,3 @@
+-- Выполненные разовые задачи помечаются временем выполнения в формате "20060102 15:04" в часовом поясе задачи
+-- и остаются в базе данных, "" - задача не выполнена
+ALTER TABLE scheduler ADD COLUMN completed_at VARCHAR(14) NOT NULL DEFAULT "";
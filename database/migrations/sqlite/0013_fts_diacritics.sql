@@ -0,0 +1,8 @@
+-- Поиск задач без удаления диакритических знаков: по умолчанию токенизатор unicode61 приравнивает
+-- "ё" к "е" и "é" к "e", а PostgreSQL (конфигурация simple) и MemoryStore различают эти буквы.
+-- Триггеры обновления индекса обращаются к таблице scheduler_fts по имени и продолжают работать
+DROP TABLE IF EXISTS scheduler_fts;
+CREATE VIRTUAL TABLE scheduler_fts USING fts5(
+	title, comment, content = 'scheduler', content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 0'
+);
+INSERT INTO scheduler_fts (scheduler_fts) VALUES ('rebuild');
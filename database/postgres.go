@@ -0,0 +1,198 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/FausT-VX/todo-list-server/database/migrations"
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/service/search"
+	"github.com/FausT-VX/todo-list-server/settings"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStore - хранилище задач в базе данных PostgreSQL.
+// Выполняет те же запросы, что и TasksStore; драйвер "postgres" регистрируется в main
+type PostgresStore struct {
+	db    *sqlx.DB
+	actor string // пользователь, от имени которого записываются изменения задач
+}
+
+var _ Repository = PostgresStore{}
+
+func NewPostgresStore(db *sqlx.DB) PostgresStore {
+	return PostgresStore{db: db}
+}
+
+// ftsDocument - индексированный текст задачи для полнотекстового поиска, совпадает с выражением индекса scheduler_fts
+const ftsDocument = "to_tsvector('simple', title || ' ' || comment)"
+
+// headlineOptions - параметры фрагмента с выделенными совпадениями, как у функции snippet в SQLite
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=%d, MinWords=%d",
+	matchStart, matchEnd, snippetWords, snippetWords/2)
+
+// OpenPostgres создает подключение к базе данных PostgreSQL по строке подключения dsn без обновления её структуры
+func OpenPostgres(dsn string) (*sqlx.DB, error) {
+	return sqlx.Connect("postgres", dsn)
+}
+
+// ConnectPostgres создает подключение к базе данных PostgreSQL по строке подключения dsn
+// и применяет к ней новые миграции
+func ConnectPostgres(dsn string) (*sqlx.DB, error) {
+	db, err := OpenPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := migrations.Up(db, false)
+	for _, migration := range applied {
+		info.Printf("Migration %04d_%s has been applied", migration.Version, migration.Name)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// GetTaskByID - получение задачи по id
+func (s PostgresStore) GetTaskByID(id int) (models.Task, error) {
+	task := models.Task{}
+	err := s.db.Get(&task, "SELECT "+taskColumns+" FROM scheduler WHERE id = $1 AND deleted_at = '' AND completed_at = ''", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrTaskNotFound
+		}
+		return models.Task{}, err
+	}
+	return task, nil
+}
+
+// DeleteTaskByID - удаление задачи по id в корзину
+func (s PostgresStore) DeleteTaskByID(id int) error {
+	return deleteTask(s.db, s.actor, id)
+}
+
+// GetTasks - получение страницы списка задач по условиям, аналогичным TasksStore.GetTasks
+func (s PostgresStore) GetTasks(query TaskQuery) (TaskPage, error) {
+	return getTasks(s.db, postgresTasks, query)
+}
+
+// postgresTasks - запросы списка задач в PostgreSQL. Полнотекстовый поиск по обоим полям использует индекс GIN
+// scheduler_fts; фрагмент с выделенными совпадениями берется из названия задачи, а если совпадений в названии нет -
+// из комментария. Названия задач сравниваются побайтово, как в SQLite
+var postgresTasks = taskDialect{
+	fullText: func(b *queryBuilder, positive []search.Term, negative []search.Term, in string) (string, string) {
+		// document возвращает индексированный текст поля, в котором ищутся слова условия term
+		document := func(term search.Term) string {
+			if column := termColumn(term, in); column != "" {
+				return "to_tsvector('simple', " + column + ")"
+			}
+			return ftsDocument
+		}
+		for _, term := range negative {
+			b.and("NOT ("+document(term)+" @@ to_tsquery('simple', ?))", tsQuery(term))
+		}
+		if len(positive) == 0 {
+			return "", ""
+		}
+		queries := []string{}
+		for _, term := range positive {
+			b.and(document(term)+" @@ to_tsquery('simple', ?)", tsQuery(term))
+			queries = append(queries, "("+tsQuery(term)+")")
+		}
+		// релевантность и фрагмент определяются по всем словам условий
+		b.join(", to_tsquery('simple', ?) AS q", strings.Join(queries, " & "))
+		options := b.bind(headlineOptions)
+		rank, snippet := ftsDocument, "CASE WHEN to_tsvector('simple', title) @@ q "+
+			"THEN ts_headline('simple', title, q, "+options+") ELSE ts_headline('simple', comment, q, "+options+") END"
+		if in != "" {
+			rank, snippet = "to_tsvector('simple', "+in+")", "ts_headline('simple', "+in+", q, "+options+")"
+		}
+		return snippet, "ts_rank(" + rank + ", q) DESC"
+	},
+	collate: ` COLLATE "C"`,
+}
+
+// UpdateTask - обновление задачи по id
+func (s PostgresStore) UpdateTask(task models.Task) error {
+	return updateTask(s.db, s.actor, models.RevisionUpdate, task)
+}
+
+// InsertTask - добавление задачи, возвращает id добавленной задачи.
+// Драйвер PostgreSQL не поддерживает LastInsertId, id возвращается предложением RETURNING
+func (s PostgresStore) InsertTask(task models.Task) (int64, error) {
+	return insertTask(s.db, s.actor, func(tx *sqlx.Tx) (int64, error) {
+		query, args, err := tx.BindNamed(`INSERT INTO scheduler (date, time, title, comment, repeat, repeat_count, repeat_until, repeat_from, overdue, tz, exceptions)
+			VALUES (:date, :time, :title, :comment, :repeat, :repeat_count, :repeat_until, :repeat_from, :overdue, :tz, :exceptions)
+			RETURNING id`, &task)
+		if err != nil {
+			return 0, err
+		}
+		var id int64
+		err = tx.Get(&id, query, args...)
+		return id, err
+	})
+}
+
+// GetHistory - получение последних записей истории повторений со статусом status,
+// для задачи с идентификатором taskID либо, если taskID равен 0, для всех задач
+func (s PostgresStore) GetHistory(taskID int, status string) ([]models.HistoryEntry, error) {
+	query := "SELECT id, task_id, date, time, title, status FROM history WHERE status = ?"
+	args := []any{status}
+	if taskID != 0 {
+		query += " AND task_id = ?"
+		args = append(args, taskID)
+	}
+	query += " ORDER BY date DESC, time DESC, id DESC LIMIT ?"
+	args = append(args, settings.Limit50)
+
+	entries := []models.HistoryEntry{}
+	if err := s.db.Select(&entries, s.db.Rebind(query), args...); err != nil {
+		return []models.HistoryEntry{}, err
+	}
+	return entries, nil
+}
+
+// CompleteTask - запись выполнения задачи и её изменение в одной транзакции, аналогично TasksStore.CompleteTask
+func (s PostgresStore) CompleteTask(completion models.Completion, missed []models.HistoryEntry, next *models.Task) error {
+	return completeTask(s.db, s.actor, completion, missed, next)
+}
+
+// GetCompletions - получение истории выполнения задач по условиям, аналогичным TasksStore.GetCompletions
+func (s PostgresStore) GetCompletions(taskID int, from string, to string) ([]models.Completion, error) {
+	return getCompletions(s.db, taskID, from, to)
+}
+
+// GetTrash - получение страницы задач из корзины по условиям query, начиная с удаленных последними
+func (s PostgresStore) GetTrash(query TrashQuery) (TaskPage, error) {
+	return getTrash(s.db, query)
+}
+
+// RestoreTask - восстановление задачи из корзины по id
+func (s PostgresStore) RestoreTask(id int) error {
+	return restoreTask(s.db, s.actor, id)
+}
+
+// PurgeTrash - окончательное удаление задач, помещенных в корзину ранее before, вместе с их историей повторений
+// и выполнения; возвращает количество удаленных задач
+func (s PostgresStore) PurgeTrash(before string) (int64, error) {
+	return purgeTrash(s.db, s.actor, before)
+}
+
+// GetRevisions - получение истории изменений задачи по taskID, начиная с последних изменений
+func (s PostgresStore) GetRevisions(taskID int) ([]models.Revision, error) {
+	return getRevisions(s.db, taskID)
+}
+
+// RevertTask - возврат задаче по taskID значений после изменения ревизией revisionID
+func (s PostgresStore) RevertTask(taskID int, revisionID int) error {
+	return revertTask(s.db, s.actor, taskID, revisionID)
+}
+
+// WithActor возвращает хранилище, записывающее изменения задач от имени пользователя actor
+func (s PostgresStore) WithActor(actor string) Repository {
+	s.actor = actor
+	return s
+}
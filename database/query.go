@@ -0,0 +1,267 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/service/search"
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// ErrInvalidCursor - курсор страницы не получен из TaskPage.NextCursor либо относится к запросу другого вида
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Поля упорядочения списка задач
+const (
+	SortDate  = "date"  // по дате и времени задачи
+	SortTitle = "title" // по названию задачи
+	SortID    = "id"    // по идентификатору задачи, то есть в порядке добавления
+)
+
+// Поля полнотекстового поиска задач
+const (
+	SearchTitle   = "title"   // только название задачи
+	SearchComment = "comment" // только комментарий задачи
+)
+
+// TaskQuery - условия получения страницы списка задач. Все заданные условия должны выполняться одновременно
+type TaskQuery struct {
+	Search   string // "" - все задачи, дата в формате 02.01.2006 - задачи на дату, иначе запрос поиска, см. пакет search
+	SearchIn string // поле поиска слов без поля: SearchTitle или SearchComment; "" - название и комментарий
+
+	From      string // задачи с датой не ранее From в формате 20060102; "" - без ограничения
+	To        string // задачи с датой не позднее To в формате 20060102; "" - без ограничения
+	Repeating *bool  // true - только повторяющиеся задачи, false - только разовые; nil - все задачи
+	Overdue   *bool  // true - только просроченные задачи, false - только непросроченные; nil - все задачи
+	Now       string // дата и время в формате "20060102 15:04", ранее которых задача просрочена; "" - текущее время
+
+	Sort string // поле упорядочения SortDate, SortTitle или SortID; "" - по релевантности для полнотекстового поиска, иначе по дате
+	Desc bool   // упорядочение по убыванию
+
+	Limit  int    // размер страницы: от 1 до settings.MaxLimit; 0 - settings.Limit50
+	Cursor string // курсор страницы из TaskPage.NextCursor; "" - первая страница
+}
+
+// TaskPage - страница списка задач
+type TaskPage struct {
+	Tasks      []models.Task
+	NextCursor string // курсор следующей страницы; "" - страница последняя
+	Total      int    // количество задач, удовлетворяющих условиям запроса, на всех страницах
+}
+
+// TrashQuery - условия получения страницы задач из корзины, упорядоченных начиная с удаленных последними
+type TrashQuery struct {
+	Limit  int    // размер страницы: от 1 до settings.MaxLimit; 0 - settings.Limit50
+	Cursor string // курсор страницы из TaskPage.NextCursor; "" - первая страница
+}
+
+// orderTrash - упорядочение курсора страницы корзины, отличное от упорядочений TaskQuery
+const orderTrash = "trash"
+
+// cursor - позиция, после которой начинается страница. При упорядочении по полю страница начинается
+// с первой задачи после задачи с ключом упорядочения Date, Time, Title, ID. Результаты полнотекстового
+// поиска, упорядоченные по релевантности, начинаются со смещения Offset
+type cursor struct {
+	Order   string `json:"s,omitempty"` // поле и направление упорядочения запроса, см. TaskQuery.order
+	Date    string `json:"d,omitempty"`
+	Time    string `json:"t,omitempty"`
+	Title   string `json:"n,omitempty"`
+	Deleted string `json:"r,omitempty"` // время удаления задачи для страниц корзины
+	ID      int    `json:"i,omitempty"`
+	Offset  int    `json:"o,omitempty"`
+}
+
+// encode возвращает курсор в виде строки для передачи клиенту
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Validate проверяет условия запроса
+func (q TaskQuery) Validate() error {
+	switch q.Sort {
+	case "", SortDate, SortTitle, SortID:
+	default:
+		return errors.New("invalid sort, must be 'date', 'title' or 'id'")
+	}
+	switch q.SearchIn {
+	case "", SearchTitle, SearchComment:
+	default:
+		return errors.New("invalid search field, must be 'title' or 'comment'")
+	}
+	for _, date := range []string{q.From, q.To} {
+		if _, err := time.Parse(settings.DateFormat, date); date != "" && err != nil {
+			return errors.New("invalid date interval, dates must be in format 20060102")
+		}
+	}
+	if _, isDate := q.date(); q.Search != "" && !isDate {
+		if _, err := search.Parse(q.Search); err != nil {
+			return err
+		}
+	}
+	if q.From != "" && q.To != "" && q.To < q.From {
+		return errors.New("end of the interval is earlier than its beginning")
+	}
+	if _, err := time.Parse(settings.DateTimeFormat, q.Now); q.Now != "" && err != nil {
+		return errors.New("invalid current time, must be in format 20060102 15:04")
+	}
+	return nil
+}
+
+// limit возвращает размер страницы с подстановкой значения по умолчанию
+func (q TaskQuery) limit() int {
+	return pageLimit(q.Limit)
+}
+
+// pageLimit возвращает размер страницы limit, ограниченный settings.MaxLimit; 0 - settings.Limit50
+func pageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return settings.Limit50
+	case limit > settings.MaxLimit:
+		return settings.MaxLimit
+	}
+	return limit
+}
+
+// date возвращает дату в формате 20060102, если строка поиска задает дату
+func (q TaskQuery) date() (string, bool) {
+	date, err := time.Parse("02.01.2006", q.Search)
+	if err != nil {
+		return "", false
+	}
+	return date.Format(settings.DateFormat), true
+}
+
+// searchQuery возвращает разобранный запрос поиска, если строка поиска не пуста и не задает дату.
+// Строка поиска должна быть проверена Validate
+func (q TaskQuery) searchQuery() (search.Query, bool) {
+	if _, isDate := q.date(); q.Search == "" || isDate {
+		return search.Query{}, false
+	}
+	query, _ := search.Parse(q.Search)
+	return query, true
+}
+
+// fullText определяет, является ли запрос полнотекстовым поиском, то есть ищет ли он слова в тексте задач
+func (q TaskQuery) fullText() bool {
+	query, ok := q.searchQuery()
+	return ok && len(query.TextTerms(false)) > 0
+}
+
+// now возвращает дату в формате 20060102 и время в формате 15:04, ранее которых задача просрочена
+func (q TaskQuery) now() (string, string) {
+	now := q.Now
+	if now == "" {
+		now = time.Now().Format(settings.DateTimeFormat)
+	}
+	return now[:len(settings.DateFormat)], now[len(settings.DateFormat)+1:]
+}
+
+// sortKeys возвращает столбцы ключа упорядочения, однозначно определяющего положение задачи в списке;
+// nil - упорядочение по релевантности результатов полнотекстового поиска
+func (q TaskQuery) sortKeys() []string {
+	sort := q.Sort
+	if sort == "" && !q.fullText() {
+		sort = SortDate
+	}
+	switch sort {
+	case SortDate:
+		return []string{"date", "time", "id"}
+	case SortTitle:
+		return []string{"title", "id"}
+	case SortID:
+		return []string{"id"}
+	}
+	return nil
+}
+
+// order возвращает поле и направление упорядочения, при котором получен курсор
+func (q TaskQuery) order() string {
+	order := q.Sort
+	if q.Desc {
+		order = "-" + order
+	}
+	return order
+}
+
+// after разбирает курсор страницы. Для первой страницы возвращает пустой курсор
+func (q TaskQuery) after() (cursor, error) {
+	var c cursor
+	if q.Cursor == "" {
+		return c, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.Order != q.order() {
+		return cursor{}, ErrInvalidCursor
+	}
+	if q.sortKeys() == nil && (c.Offset <= 0 || c.ID != 0) || q.sortKeys() != nil && (c.ID <= 0 || c.Offset != 0) {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// keyValues возвращает значения столбцов ключа упорядочения sortKeys из курсора c
+func (q TaskQuery) keyValues(c cursor) []any {
+	values := map[string]any{"date": c.Date, "time": c.Time, "title": c.Title, "id": c.ID}
+	var keys []any
+	for _, key := range q.sortKeys() {
+		keys = append(keys, values[key])
+	}
+	return keys
+}
+
+// newPage возвращает страницу из задач tasks, полученных с запасом в одну задачу для определения
+// наличия следующей страницы. total - количество задач на всех страницах
+func (q TaskQuery) newPage(tasks []models.Task, after cursor, total int) TaskPage {
+	page := TaskPage{Tasks: tasks, Total: total}
+	limit := q.limit()
+	if len(tasks) <= limit {
+		return page
+	}
+	page.Tasks = tasks[:limit]
+	if q.sortKeys() == nil {
+		page.NextCursor = cursor{Order: q.order(), Offset: after.Offset + limit}.encode()
+	} else {
+		last := page.Tasks[limit-1]
+		id, _ := strconv.Atoi(last.ID)
+		page.NextCursor = cursor{Order: q.order(), Date: last.Date, Time: last.Time, Title: last.Title, ID: id}.encode()
+	}
+	return page
+}
+
+// limit возвращает размер страницы корзины с подстановкой значения по умолчанию
+func (q TrashQuery) limit() int {
+	return pageLimit(q.Limit)
+}
+
+// after разбирает курсор страницы корзины. Для первой страницы возвращает пустой курсор
+func (q TrashQuery) after() (cursor, error) {
+	var c cursor
+	if q.Cursor == "" {
+		return c, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.Order != orderTrash || c.Deleted == "" || c.ID <= 0 {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// newPage возвращает страницу корзины из задач tasks, полученных с запасом в одну задачу, аналогично TaskQuery.newPage
+func (q TrashQuery) newPage(tasks []models.Task, total int) TaskPage {
+	page := TaskPage{Tasks: tasks, Total: total}
+	limit := q.limit()
+	if len(tasks) <= limit {
+		return page
+	}
+	page.Tasks = tasks[:limit]
+	last := page.Tasks[limit-1]
+	id, _ := strconv.Atoi(last.ID)
+	page.NextCursor = cursor{Order: orderTrash, Deleted: last.DeletedAt, ID: id}.encode()
+	return page
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/settings"
+	"github.com/jmoiron/sqlx"
+)
+
+// Изменения задач в базах данных SQLite и PostgreSQL выполняются в транзакции вместе с записью ревизии
+// в историю изменений, поэтому история содержит все изменения задач. Запросы записываются со знаками "?",
+// которые заменяются параметрами драйвера методом Rebind
+
+// revisionColumns - список столбцов таблицы revisions, соответствующих полям models.Revision
+const revisionColumns = "id, task_id, action, changed_at, actor, old_task, new_task"
+
+// utcNow возвращает текущее время UTC в формате "20060102 15:04" для столбцов deleted_at и changed_at
+func utcNow() string {
+	return time.Now().UTC().Format(settings.DateTimeFormat)
+}
+
+// newRevision возвращает ревизию с действием action от имени actor, изменившую задачу old на task; old = nil - задача добавлена
+func newRevision(action string, actor string, old *models.Task, task models.Task) (models.Revision, error) {
+	revision := models.Revision{TaskID: task.ID, Action: action, ChangedAt: utcNow(), Actor: actor}
+	if old != nil {
+		data, err := json.Marshal(old)
+		if err != nil {
+			return models.Revision{}, err
+		}
+		revision.OldTask = string(data)
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	revision.NewTask = string(data)
+	return revision, nil
+}
+
+// revisionTask возвращает задачу со значениями после изменения ревизией revision
+func revisionTask(revision models.Revision) (models.Task, error) {
+	task := models.Task{}
+	err := json.Unmarshal([]byte(revision.NewTask), &task)
+	return task, err
+}
+
+// selectTask возвращает задачу по id, в том числе находящуюся в корзине.
+// В PostgreSQL строка задачи блокируется до конца транзакции tx, SQLite блокирует запись на время всей транзакции сам
+func selectTask(tx *sqlx.Tx, id int) (models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM scheduler WHERE id = ?"
+	if tx.DriverName() == "postgres" {
+		query += " FOR UPDATE"
+	}
+	task := models.Task{}
+	err := tx.Get(&task, tx.Rebind(query), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Task{}, ErrTaskNotFound
+	}
+	return task, err
+}
+
+// insertRevision записывает в историю изменений ревизию с действием action от имени actor, изменившую задачу old на task
+func insertRevision(tx *sqlx.Tx, action string, actor string, old *models.Task, task models.Task) error {
+	revision, err := newRevision(action, actor, old, task)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NamedExec(`INSERT INTO revisions (task_id, action, changed_at, actor, old_task, new_task)
+		VALUES (:task_id, :action, :changed_at, :actor, :old_task, :new_task)`, &revision)
+	return err
+}
+
+// insertTask добавляет задачу функцией insert, возвращающей id добавленной задачи, и записывает ревизию от имени actor
+func insertTask(db *sqlx.DB, actor string, insert func(tx *sqlx.Tx) (int64, error)) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := insert(tx)
+	if err != nil {
+		return 0, err
+	}
+	task, err := selectTask(tx, int(id))
+	if err != nil {
+		return 0, err
+	}
+	if err := insertRevision(tx, models.RevisionInsert, actor, nil, task); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// changeTask изменяет функцией change задачу по id, находящуюся в корзине (inTrash = true) или вне её,
+// и записывает ревизию с действием action от имени actor. Функция change получает значения задачи до изменения.
+// Выполненные задачи не изменяются
+func changeTask(db *sqlx.DB, actor string, action string, id int, inTrash bool, change func(tx *sqlx.Tx, old models.Task) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := selectTask(tx, id)
+	if err != nil {
+		return err
+	}
+	if old.CompletedAt != "" || (old.DeletedAt != "") != inTrash {
+		return ErrTaskNotFound
+	}
+	if err := change(tx, old); err != nil {
+		return err
+	}
+	task, err := selectTask(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := insertRevision(tx, action, actor, &old, task); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateTask записывает значения задачи task, не находящейся в корзине, и ревизию с действием action от имени actor
+func updateTask(db *sqlx.DB, actor string, action string, task models.Task) error {
+	id, err := strconv.Atoi(strings.TrimSpace(task.ID))
+	if err != nil {
+		return ErrTaskNotFound
+	}
+	task.ID = strconv.Itoa(id)
+	return changeTask(db, actor, action, id, false, func(tx *sqlx.Tx, _ models.Task) error {
+		return writeTask(tx, task)
+	})
+}
+
+// writeTask записывает в транзакции tx значения задачи task, кроме отметок об удалении и выполнении
+func writeTask(tx *sqlx.Tx, task models.Task) error {
+	_, err := tx.NamedExec(`UPDATE scheduler SET date = :date, time = :time, title = :title, comment = :comment, repeat = :repeat,
+		repeat_count = :repeat_count, repeat_until = :repeat_until, repeat_from = :repeat_from, overdue = :overdue, tz = :tz,
+		exceptions = :exceptions WHERE id = :id`, &task)
+	return err
+}
+
+// completeTask записывает выполнение задачи completion, пропущенные повторения missed и ревизию от имени actor,
+// переносит задачу на следующее повторение next либо, если next = nil, отмечает её выполненной.
+// Если задача изменена после её получения для выполнения, возвращает ErrTaskChanged
+func completeTask(db *sqlx.DB, actor string, completion models.Completion, missed []models.HistoryEntry, next *models.Task) error {
+	id, err := strconv.Atoi(strings.TrimSpace(completion.TaskID))
+	if err != nil {
+		return ErrTaskNotFound
+	}
+	return changeTask(db, actor, models.RevisionDone, id, false, func(tx *sqlx.Tx, old models.Task) error {
+		// повторный запрос выполнения той же даты повторения не записывается дважды
+		if old.Date != completion.Date || old.Time != completion.Time {
+			return ErrTaskChanged
+		}
+		if len(missed) > 0 {
+			_, err := tx.NamedExec(`INSERT INTO history (task_id, date, time, title, status)
+				VALUES (:task_id, :date, :time, :title, :status)`, missed)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.NamedExec(`INSERT INTO completions (task_id, date, time, completed_at, title, comment, repeat)
+			VALUES (:task_id, :date, :time, :completed_at, :title, :comment, :repeat)`, &completion)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			_, err = tx.Exec(tx.Rebind("UPDATE scheduler SET completed_at = ? WHERE id = ?"), completion.CompletedAt, id)
+			return err
+		}
+		task := *next
+		task.ID = strconv.Itoa(id)
+		return writeTask(tx, task)
+	})
+}
+
+// deleteTask удаляет задачу по id в корзину и записывает ревизию от имени actor
+func deleteTask(db *sqlx.DB, actor string, id int) error {
+	return changeTask(db, actor, models.RevisionDelete, id, false, func(tx *sqlx.Tx, _ models.Task) error {
+		_, err := tx.Exec(tx.Rebind("UPDATE scheduler SET deleted_at = ? WHERE id = ?"), utcNow(), id)
+		return err
+	})
+}
+
+// restoreTask восстанавливает задачу по id из корзины и записывает ревизию от имени actor
+func restoreTask(db *sqlx.DB, actor string, id int) error {
+	return changeTask(db, actor, models.RevisionRestore, id, true, func(tx *sqlx.Tx, _ models.Task) error {
+		_, err := tx.Exec(tx.Rebind("UPDATE scheduler SET deleted_at = '' WHERE id = ?"), id)
+		return err
+	})
+}
+
+// purgeTrash окончательно удаляет задачи, помещенные в корзину ранее before, вместе с их историей повторений
+// и выполнения и записывает для каждой задачи ревизию удаления от имени actor. Ревизии удаленных задач сохраняются
+func purgeTrash(db *sqlx.DB, actor string, before string) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	tasks := []models.Task{}
+	err = tx.Select(&tasks, tx.Rebind("SELECT "+taskColumns+" FROM scheduler WHERE deleted_at <> '' AND deleted_at < ?"), before)
+	if err != nil {
+		return 0, err
+	}
+	for _, task := range tasks {
+		for _, query := range []string{
+			"DELETE FROM history WHERE task_id = ?",
+			"DELETE FROM completions WHERE task_id = ?",
+			"DELETE FROM scheduler WHERE id = ?",
+		} {
+			if _, err := tx.Exec(tx.Rebind(query), task.ID); err != nil {
+				return 0, err
+			}
+		}
+		if err := insertRevision(tx, models.RevisionPurge, actor, &task, models.Task{ID: task.ID}); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(tasks)), tx.Commit()
+}
+
+// getRevisions возвращает историю изменений задачи по taskID, начиная с последних изменений
+func getRevisions(db *sqlx.DB, taskID int) ([]models.Revision, error) {
+	revisions := []models.Revision{}
+	err := db.Select(&revisions, db.Rebind("SELECT "+revisionColumns+" FROM revisions WHERE task_id = ? ORDER BY id DESC LIMIT ?"),
+		taskID, settings.MaxLimit)
+	if err != nil {
+		return []models.Revision{}, err
+	}
+	return revisions, nil
+}
+
+// revertTask возвращает задаче по taskID значения после изменения ревизией revisionID и записывает ревизию от имени actor.
+// Ревизия читается и применяется в одной транзакции с изменением задачи
+func revertTask(db *sqlx.DB, actor string, taskID int, revisionID int) error {
+	return changeTask(db, actor, models.RevisionRevert, taskID, false, func(tx *sqlx.Tx, _ models.Task) error {
+		revision := models.Revision{}
+		err := tx.Get(&revision, tx.Rebind("SELECT "+revisionColumns+" FROM revisions WHERE id = ? AND task_id = ?"), revisionID, taskID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRevisionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		task, err := revisionTask(revision)
+		if err != nil {
+			return err
+		}
+		task.ID = strconv.Itoa(taskID)
+		return writeTask(tx, task)
+	})
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"html"
+	"slices"
+	"strings"
+
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/service/search"
+)
+
+// Метки выделения совпадений в поле snippet результатов поиска
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// Метки совпадений во фрагменте, который возвращает СУБД или выделяет MemoryStore: управляющие символы,
+// которые после экранирования текста фрагмента заменяются на HighlightStart и HighlightEnd
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// snippetHTML экранирует текст фрагмента snippet с метками matchStart и matchEnd для вставки в HTML
+// и заменяет метки тегами HighlightStart и HighlightEnd
+func snippetHTML(snippet string) string {
+	return strings.NewReplacer(matchStart, HighlightStart, matchEnd, HighlightEnd).Replace(html.EscapeString(snippet))
+}
+
+// snippetWords - наибольшее количество слов во фрагменте с выделенными совпадениями
+const snippetWords = 12
+
+// termColumn возвращает поле задачи, в котором ищутся слова условия term; in - поле для условий без поля
+func termColumn(term search.Term, in string) string {
+	if term.Field != search.FieldText {
+		return term.Field
+	}
+	return in
+}
+
+// ftsQuery возвращает запрос FTS5 для условий terms без учета отрицания: каждое слово условия
+// совпадает с началом слова текста, слова фразы совпадают целиком и идут подряд
+func ftsQuery(terms []search.Term, in string) string {
+	var phrases []string
+	for _, term := range terms {
+		column := termColumn(term, in)
+		if column != "" {
+			column += " : "
+		}
+		if term.Phrase {
+			phrases = append(phrases, column+`"`+strings.Join(term.Words, " ")+`"`)
+			continue
+		}
+		for _, word := range term.Words {
+			phrases = append(phrases, column+`"`+word+`"*`)
+		}
+	}
+	return strings.Join(phrases, " ")
+}
+
+// tsQuery возвращает запрос tsquery PostgreSQL для условия term с тем же смыслом, что и ftsQuery
+func tsQuery(term search.Term) string {
+	if term.Phrase {
+		return strings.Join(term.Words, " <-> ")
+	}
+	return strings.Join(term.Words, ":* & ") + ":*"
+}
+
+// fieldCondition возвращает условие SQL для условия term по дате или виду правила повторения
+// с параметрами на месте знаков "?"
+func fieldCondition(term search.Term) (string, []any) {
+	var cond string
+	var values []any
+	switch {
+	case term.Field == search.FieldDate:
+		cond, values = "date "+term.Op+" ?", []any{term.Value}
+	case term.Value == search.RepeatNone:
+		cond = "repeat = ''"
+	default:
+		// вид правила - первое слово правила повторения
+		cond, values = "repeat = ? OR repeat LIKE ?", []any{term.Value, term.Value + " %"}
+	}
+	if term.Negate {
+		cond = "NOT (" + cond + ")"
+	}
+	return cond, values
+}
+
+// taskWords - слова полей задачи в нижнем регистре
+type taskWords map[string][]string
+
+func newTaskWords(task models.Task) taskWords {
+	return taskWords{search.FieldTitle: search.Words(task.Title), search.FieldComment: search.Words(task.Comment)}
+}
+
+// match определяет, выполняется ли для задачи task со словами полей words условие term без учета отрицания;
+// in - поле для условий без поля
+func (words taskWords) match(task models.Task, term search.Term, in string) bool {
+	switch term.Field {
+	case search.FieldDate:
+		switch cmp := strings.Compare(task.Date, term.Value); term.Op {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		default:
+			return cmp == 0
+		}
+	case search.FieldRepeat:
+		if term.Value == search.RepeatNone {
+			return task.Repeat == ""
+		}
+		kind, _, _ := strings.Cut(task.Repeat, " ")
+		return kind == term.Value
+	}
+
+	columns := []string{search.FieldTitle, search.FieldComment}
+	if column := termColumn(term, in); column != "" {
+		columns = []string{column}
+	}
+	if term.Phrase {
+		return slices.ContainsFunc(columns, func(column string) bool {
+			text := words[column]
+			for i := 0; i+len(term.Words) <= len(text); i++ {
+				if slices.Equal(text[i:i+len(term.Words)], term.Words) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	for _, word := range term.Words {
+		if !slices.ContainsFunc(columns, func(column string) bool {
+			return slices.ContainsFunc(words[column], func(w string) bool { return strings.HasPrefix(w, word) })
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// wordMatcher возвращает функцию, определяющую, совпадает ли слово поля column со словами условий terms
+func wordMatcher(terms []search.Term, column string, in string) func(word string) bool {
+	return func(word string) bool {
+		return slices.ContainsFunc(terms, func(term search.Term) bool {
+			if c := termColumn(term, in); c != "" && c != column {
+				return false
+			}
+			return slices.ContainsFunc(term.Words, func(w string) bool {
+				return word == w || !term.Phrase && strings.HasPrefix(word, w)
+			})
+		})
+	}
+}
+
+// textMatch - совпадения слов поиска с текстом поля задачи
+type textMatch struct {
+	text string // текст с выделенными совпадениями
+	hits int    // количество совпавших слов текста
+}
+
+// highlight выделяет метками matchStart и matchEnd слова текста text, для которых выполняется match
+func highlight(text string, match func(word string) bool) textMatch {
+	var result textMatch
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !search.IsWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && search.IsWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if match(strings.ToLower(word)) {
+			result.hits++
+			b.WriteString(matchStart + word + matchEnd)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	result.text = b.String()
+	return result
+}
@@ -3,15 +3,21 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/FausT-VX/todo-list-server/database"
 	"github.com/FausT-VX/todo-list-server/models"
@@ -30,6 +36,45 @@ type TaskID struct {
 	ID int64 `json:"id"`
 }
 
+// RepeatValidation - запрос и результат проверки правила повторения
+type RepeatValidation struct {
+	Repeat      string   `json:"repeat"`
+	Date        string   `json:"date,omitempty"`        // дата начала отсчёта повторений, по умолчанию - текущая
+	Valid       bool     `json:"valid"`                 // признак корректности правила
+	Token       *int     `json:"token,omitempty"`       // индекс ошибочного токена правила
+	Value       string   `json:"value,omitempty"`       // значение ошибочного токена правила
+	Reason      string   `json:"reason,omitempty"`      // причина ошибки
+	Error       string   `json:"error,omitempty"`       // полный текст ошибки
+	Description string   `json:"description,omitempty"` // описание правила повторения
+	Next        []string `json:"next,omitempty"`        // ближайшие даты повторения
+}
+
+// TaskException - исключение из серии повторений задачи: пропуск повторения либо его перенос на другой день
+type TaskException struct {
+	ID   string `json:"id"`
+	Date string `json:"date"`         // день повторения в формате 20060102
+	To   string `json:"to,omitempty"` // новый день повторения в формате 20060102, пустой - повторение пропускается
+}
+
+// Occurrence - повторение задачи на конкретную дату
+type Occurrence struct {
+	models.Task
+	Projected bool `json:"projected"` // true - будущее повторение задачи, false - текущая дата задачи
+}
+
+// TaskRevision - ревизия задачи с изменениями её полей
+type TaskRevision struct {
+	models.Revision
+	Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange - изменение поля задачи: значения до и после изменения
+type FieldChange struct {
+	Field string `json:"field"` // имя поля задачи в JSON
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
 // NextDateHandler получает следующую дату повторения задачи по переданным в http-запросе параметрам
 // now, date, repeat
 func NextDateHandler(w http.ResponseWriter, r *http.Request) {
@@ -38,12 +83,17 @@ func NextDateHandler(w http.ResponseWriter, r *http.Request) {
 	date := r.FormValue("date")
 	repeat := r.FormValue("repeat")
 
+	loc, err := requestLocation(r)
+	if err != nil {
+		http.Error(w, errorJSON(err), http.StatusBadRequest)
+		return
+	}
+
 	//Вычисляем следующую дату
-	nextDate, err := scheduler.NextDate(now, date, repeat)
+	nextDate, err := scheduler.NextDateIn(now, date, repeat, loc)
 	if err != nil {
 		log.Printf("NextDateHandler: %v %v %v %v; error: %v\n", now, date, repeat, nextDate, err)
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(""))
+		http.Error(w, errorJSON(err), http.StatusBadRequest)
 		return
 	}
 
@@ -53,9 +103,97 @@ func NextDateHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(nextDate) /*jsonResp*/)
 }
 
+// DescribeRepeatHandler возвращает описание правила повторения repeat на языке lang в формате JSON
+func DescribeRepeatHandler(w http.ResponseWriter, r *http.Request) {
+	repeat := r.FormValue("repeat")
+	description, err := scheduler.Describe(repeat, requestLang(r))
+	if err != nil {
+		log.Printf("DescribeRepeatHandler: repeat = %v; error: %v\n", repeat, err)
+		http.Error(w, errorJSON(err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"description": description})
+}
+
+// ValidateRepeatHandler проверяет переданное в json правило повторения repeat и возвращает результат проверки:
+// признак корректности, позицию и причину ошибки либо ближайшие даты повторения
+func ValidateRepeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		err := errors.New("method not supported")
+		http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request RepeatValidation
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, errorJSON(err), http.StatusBadRequest)
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		http.Error(w, errorJSON(err), http.StatusBadRequest)
+		return
+	}
+	now := time.Now().In(loc).Format(settings.DateTimeFormat)
+	date := strings.TrimSpace(request.Date)
+	if date == "" {
+		date, _ = scheduler.SplitDateTime(now)
+	}
+	if _, err := time.Parse(settings.DateFormat, date); err != nil {
+		if _, err = time.Parse(settings.DateTimeFormat, date); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result := RepeatValidation{Repeat: request.Repeat, Date: date}
+	next, err := scheduler.UpcomingDates(now, date, request.Repeat, settings.ValidateNextCount, loc)
+	if err != nil {
+		result.Error = err.Error()
+		result.Reason = err.Error()
+		var ruleErr *scheduler.RuleError
+		if errors.As(err, &ruleErr) {
+			result.Token = &ruleErr.Token
+			result.Value = ruleErr.Value
+			result.Reason = ruleErr.Reason
+		}
+	} else {
+		result.Valid = true
+		result.Next = next
+		result.Description, _ = scheduler.Describe(request.Repeat, requestLang(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// RepeatRulesHandler возвращает сведения о поддерживаемых видах правил повторения в формате JSON:
+// обозначение, синтаксис, пример с описанием на языке lang и допустимые модификаторы
+func RepeatRulesHandler(w http.ResponseWriter, r *http.Request) {
+	response := map[string][]scheduler.RuleInfo{"rules": scheduler.Rules(requestLang(r))}
+	jsonResponse, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		http.Error(w, errorJSON(err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(jsonResponse)
+}
+
 // GetTasks обработчик возвращает все задачи из БД в формате списка JSON либо,
-// при наличии параметра search, возвращает задачи по переданным параметрам
-func GetTasks(store database.TasksStore) http.HandlerFunc {
+// при наличии параметра search, возвращает задачи по переданным параметрам.
+// Фильтры: from и to - интервал дат в формате 20060102, repeating=true|false - повторяющиеся или разовые задачи,
+// overdue=true|false - просроченные или непросроченные задачи, in=title|comment - поле для поиска по словам.
+// Упорядочение: sort=date|title|id и order=asc|desc.
+// Параметры limit (размер страницы) и cursor (курсор из next_cursor предыдущей страницы) задают
+// постраничное получение задач; в этом случае ответ дополнительно содержит next_cursor и total
+func GetTasks(store database.TaskRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			err := errors.New("method not supported")
@@ -63,14 +201,130 @@ func GetTasks(store database.TasksStore) http.HandlerFunc {
 			return
 		}
 
-		search := r.URL.Query().Get("search")
-		tasks, err := store.GetTasks(search)
+		query, err := taskQuery(r)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		values := r.URL.Query()
+		paged := values.Has("limit") || values.Has("cursor")
+		page, err := store.GetTasks(query)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Handler GetTasks: query = %v; err = %v\n", query, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+		lang := requestLang(r)
+		for i := range page.Tasks {
+			describeRepeat(&page.Tasks[i], lang)
+		}
+
+		var response any = map[string][]models.Task{"tasks": page.Tasks}
+		if paged {
+			response = map[string]any{"tasks": page.Tasks, "next_cursor": page.NextCursor, "total": page.Total}
+		}
+		jsonResponse, err := json.MarshalIndent(response, "", "  ")
 		if err != nil {
-			log.Printf("Handler GetTasks: search = %v; err = %v\n", search, err)
 			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(jsonResponse)
+	}
+}
+
+// GetOccurrences обработчик возвращает все повторения задач в интервале дат from - to (в формате 20060102)
+// в формате списка JSON. По умолчанию интервал начинается с текущей даты и длится один месяц
+func GetOccurrences(store database.TaskRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		loc, err := requestLocation(r)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		fromDate := time.Now().In(loc)
+		if from := strings.TrimSpace(r.URL.Query().Get("from")); from != "" {
+			date, err := time.ParseInLocation(settings.DateFormat, from, loc)
+			if err != nil {
+				http.Error(w, errorJSON(err), http.StatusBadRequest)
+				return
+			}
+			fromDate = date
+		}
+		toDate := fromDate.AddDate(0, 1, 0)
+		if to := strings.TrimSpace(r.URL.Query().Get("to")); to != "" {
+			date, err := time.ParseInLocation(settings.DateFormat, to, loc)
+			if err != nil {
+				http.Error(w, errorJSON(err), http.StatusBadRequest)
+				return
+			}
+			toDate = date
+		}
+		if toDate.Before(fromDate) || toDate.After(fromDate.AddDate(0, 0, settings.MaxIntervalDays)) {
+			err := fmt.Errorf("interval must be non-negative and not longer than %d days", settings.MaxIntervalDays)
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		from, to := fromDate.Format(settings.DateFormat), toDate.Format(settings.DateFormat)
+
+		// задачи получаются страницами наибольшего размера до последней страницы
+		var tasks []models.Task
+		query := database.TaskQuery{Limit: settings.MaxLimit}
+		for {
+			page, err := store.GetTasks(query)
+			if err != nil {
+				log.Printf("Handler GetOccurrences: from = %v; to = %v; err = %v\n", from, to, err)
+				http.Error(w, errorJSON(err), http.StatusInternalServerError)
+				return
+			}
+			tasks = append(tasks, page.Tasks...)
+			if query.Cursor = page.NextCursor; query.Cursor == "" {
+				break
+			}
+		}
+
+		// для каждой задачи находим все её повторения в интервале
+		occurrences := []Occurrence{}
+		for _, task := range tasks {
+			series, err := taskSeries(task, loc)
+			if err != nil {
+				log.Printf("Handler GetOccurrences: task = %v; err = %v\n", task, err)
+				continue
+			}
+			dates, err := series.Occurrences(from, to)
+			if errors.Is(err, scheduler.ErrTooManyOccurrences) {
+				// усечённый список повторений выглядел бы полным, поэтому интервал отклоняется целиком
+				log.Printf("Handler GetOccurrences: task = %v; err = %v\n", task, err)
+				http.Error(w, errorJSON(fmt.Errorf("task %s: %w", task.ID, err)), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Handler GetOccurrences: task = %v; err = %v\n", task, err)
+				continue
+			}
+			for _, date := range dates {
+				occurrence := Occurrence{Task: task, Projected: date != scheduler.JoinDateTime(task.Date, task.Time)}
+				occurrence.Date, occurrence.Time = scheduler.SplitDateTime(date)
+				occurrences = append(occurrences, occurrence)
+			}
 		}
+		slices.SortStableFunc(occurrences, func(a, b Occurrence) int {
+			return strings.Compare(scheduler.JoinDateTime(a.Date, a.Time), scheduler.JoinDateTime(b.Date, b.Time))
+		})
 
-		response := map[string][]models.Task{"tasks": tasks}
+		response := map[string][]Occurrence{"occurrences": occurrences}
 		jsonResponse, err := json.MarshalIndent(response, "", "  ")
 		if err != nil {
 			http.Error(w, errorJSON(err), http.StatusInternalServerError)
@@ -84,7 +338,7 @@ func GetTasks(store database.TasksStore) http.HandlerFunc {
 }
 
 // GetTaskByID обработчик возвращает задачу по переданному ID
-func GetTaskByID(store database.TasksStore) http.HandlerFunc {
+func GetTaskByID(store database.TaskRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := r.URL.Query().Get("id")
 		if strings.TrimSpace(idParam) == "" {
@@ -105,6 +359,7 @@ func GetTaskByID(store database.TasksStore) http.HandlerFunc {
 			http.Error(w, errorJSON(err), http.StatusInternalServerError)
 			return
 		}
+		describeRepeat(&task, requestLang(r))
 
 		resp, err := json.MarshalIndent(&task, "", "  ")
 		if err != nil {
@@ -121,8 +376,9 @@ func GetTaskByID(store database.TasksStore) http.HandlerFunc {
 
 // PostTask обработчик создает новую задачу по переданным в http-запросе параметрам,
 // записывая в БД с переданными параметрами и записывает в БД
-func PostTask(store database.TasksStore) http.HandlerFunc {
+func PostTask(store database.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
 		if r.Method != http.MethodPost {
 			err := errors.New("method not supported")
 			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
@@ -152,30 +408,19 @@ func PostTask(store database.TasksStore) http.HandlerFunc {
 			return
 		}
 
-		date := strings.TrimSpace(task.Date)
-		now := time.Now().Format(settings.DateFormat)
-		nextDate := ""
+		if err = convertRRULE(&task, r); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
 
-		if len(date) == 0 {
-			task.Date = now
-		} else {
-			begDate, err := time.Parse(settings.DateFormat, date)
-			if err != nil {
-				http.Error(w, errorJSON(err), http.StatusBadRequest)
-				return
-			}
-			if begDate.Before(time.Now()) {
-				if repeat := strings.TrimSpace(task.Repeat); repeat == "" {
-					task.Date = now
-				} else {
-					nextDate, err = scheduler.NextDate(now, date, task.Repeat)
-					if err != nil {
-						http.Error(w, errorJSON(err), http.StatusBadRequest)
-						return
-					}
-					task.Date = nextDate
-				}
-			}
+		if err = adjustTaskDate(&task, r); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+
+		if err = checkRepeatEnd(&task); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
 		}
 
 		lastID, err := store.InsertTask(task)
@@ -193,10 +438,12 @@ func PostTask(store database.TasksStore) http.HandlerFunc {
 	}
 }
 
-// PostTaskDone обработчик удаляет задачу по переданному ID если не задано правило повторения
-// либо обновляет дату следующего повторения по правилу указанному в задаче
-func PostTaskDone(store database.TasksStore) http.HandlerFunc {
+// PostTaskDone обработчик записывает выполнение задачи по переданному ID в историю выполнения и отмечает задачу
+// выполненной если не задано правило повторения или повторения задачи исчерпаны, либо обновляет дату следующего
+// повторения по правилу указанному в задаче. Запись выполнения и изменение задачи выполняются в одной транзакции
+func PostTaskDone(store database.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
 		if r.Method != http.MethodPost {
 			err := errors.New("method not supported")
 			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
@@ -223,44 +470,93 @@ func PostTaskDone(store database.TasksStore) http.HandlerFunc {
 			return
 		}
 
-		if strings.TrimSpace(task.Repeat) == "" {
-
-			if err := store.DeleteTaskByID(id); err != nil {
-				log.Printf("Handler PostTaskDone: id = %v; task = %v; error = %v\n", id, task, err)
+		loc, err := requestLocation(r)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		nextDate := ""
+		var crossed []string // повторения между текущим и следующим повторением задачи
+		missed := []models.HistoryEntry{}
+		if strings.TrimSpace(task.Repeat) != "" {
+			// получаем новую дату повторения задачи с учетом режима отсчёта и условий окончания повторений:
+			// при отсчёте от даты выполнения и для повторений по часам и минутам передаётся текущее время
+			series, err := taskSeries(task, loc)
+			if err != nil {
 				http.Error(w, errorJSON(err), http.StatusInternalServerError)
 				return
 			}
-
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("{}"))
-			return
+			now := time.Now().In(series.Location)
+			if task.RepeatFrom != scheduler.RepeatFromCompletion && !scheduler.IsTimeRule(task.Repeat) {
+				now = now.Add(time.Hour * 25)
+			}
+			nextDate, err = series.NextDate(now.Format(settings.DateTimeFormat))
+			if err != nil {
+				http.Error(w, errorJSON(err), http.StatusInternalServerError)
+				return
+			}
+			// просроченные повторения, через которые перешла задача, учитываются в количестве повторений,
+			// а при политике OverdueRecord записываются в историю
+			if task.RepeatFrom != scheduler.RepeatFromCompletion {
+				if crossed, err = series.Missed(nextDate); err != nil {
+					log.Printf("Handler PostTaskDone: id = %v; task = %v; error = %v\n", id, task, err)
+					http.Error(w, errorJSON(err), http.StatusInternalServerError)
+					return
+				}
+			}
+			if task.Overdue == scheduler.OverdueRecord {
+				missed = missedEntries(task, crossed)
+			}
+			if task.RepeatCount > 0 && task.RepeatCount-1-len(crossed) <= 0 {
+				nextDate = ""
+			}
 		}
-		// получаем новую дату повторения задачи и записываем в базу
-		now := time.Now().Add(time.Hour * 25).Format(settings.DateFormat)
-		task.Date, err = scheduler.NextDate(now, task.Date, task.Repeat)
+
+		// выполнение записывается в историю с копией задачи до её изменения
+		completion, err := newCompletion(task, loc)
 		if err != nil {
 			http.Error(w, errorJSON(err), http.StatusInternalServerError)
 			return
+		}
 
+		// задача без правила повторения либо с исчерпанными повторениями отмечается выполненной,
+		// иначе записываем новую дату повторения и уменьшаем оставшееся количество повторений на выполненное
+		// и пройденные просроченные повторения
+		var next *models.Task
+		status := http.StatusOK
+		if nextDate != "" {
+			next, status = &task, http.StatusCreated
+			task.Date, task.Time = scheduler.SplitDateTime(nextDate)
+			if task.RepeatCount > 0 {
+				task.RepeatCount -= 1 + len(crossed)
+			}
+			// исключения для прошедших повторений больше не нужны
+			if exceptions, err := scheduler.ParseExceptions(task.Exceptions); err == nil {
+				exceptions.Prune(exceptions.Origin(nextDate))
+				task.Exceptions = exceptions.String()
+			}
 		}
 
-		err = store.UpdateTask(task)
-		if err != nil {
+		if err := store.CompleteTask(completion, missed, next); err != nil {
 			log.Printf("Handler PostTaskDone: id = %v; task = %v; error = %v\n", id, task, err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			if errors.Is(err, database.ErrTaskChanged) {
+				status = http.StatusConflict
+			}
+			http.Error(w, errorJSON(err), status)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(status)
 		_, _ = w.Write([]byte("{}"))
 	}
 }
 
 // PutTask обработчик обновляет задачу переданными в json данными, получая ее из базы по ID
-func PutTask(store database.TasksStore) http.HandlerFunc {
+func PutTask(store database.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
 		if r.Method != http.MethodPut {
 			err := errors.New("method not supported")
 			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
@@ -297,32 +593,19 @@ func PutTask(store database.TasksStore) http.HandlerFunc {
 			return
 		}
 
-		date := strings.TrimSpace(task.Date)
-		now := time.Now().Format(settings.DateFormat)
-		nextDate := ""
-		if repeat := strings.TrimSpace(task.Repeat); repeat != "" {
-			nextDate, err = scheduler.NextDate(now, date, task.Repeat)
-			if err != nil {
-				http.Error(w, errorJSON(err), http.StatusBadRequest)
-				return
-			}
+		if err = convertRRULE(&task, r); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
 		}
 
-		if len(date) == 0 {
-			task.Date = now
-		} else {
-			begDate, err := time.Parse(settings.DateFormat, date)
-			if err != nil {
-				http.Error(w, errorJSON(err), http.StatusBadRequest)
-				return
-			}
-			if begDate.Before(time.Now()) {
-				if repeat := strings.TrimSpace(task.Repeat); repeat == "" {
-					task.Date = now
-				} else {
-					task.Date = nextDate
-				}
-			}
+		if err = adjustTaskDate(&task, r); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+
+		if err = checkRepeatEnd(&task); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
 		}
 
 		err = store.UpdateTask(task)
@@ -338,9 +621,10 @@ func PutTask(store database.TasksStore) http.HandlerFunc {
 	}
 }
 
-// DeleteTask обработчик удаляет задачу из базы по ID
-func DeleteTask(store database.TasksStore) http.HandlerFunc {
+// DeleteTask обработчик удаляет задачу по ID в корзину
+func DeleteTask(store database.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
 		if r.Method != http.MethodDelete {
 			err := errors.New("method not supported")
 			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
@@ -370,59 +654,516 @@ func DeleteTask(store database.TasksStore) http.HandlerFunc {
 	}
 }
 
-type Credentials struct {
-	Password string `json:"password"`
-}
-
-type Response struct {
-	Token string `json:"token,omitempty"`
-	Error string `json:"error,omitempty"`
-}
+// GetHistory обработчик возвращает историю выполнения задач, начиная с последних выполненных, в формате списка JSON.
+// Параметры from и to (в формате 20060102) ограничивают даты выполнения, параметр id - задачу
+func GetHistory(store database.CompletionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
 
-// AuthHandler обработчик аутентификации пользователя по паролю
-func AuthHandler(w http.ResponseWriter, r *http.Request) {
-	var creds Credentials
-	err := json.NewDecoder(r.Body).Decode(&creds)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Response{Error: "Invalid request payload"})
-		return
-	}
+		values := r.URL.Query()
+		id := 0
+		if idParam := strings.TrimSpace(values.Get("id")); idParam != "" {
+			var err error
+			if id, err = strconv.Atoi(idParam); err != nil {
+				http.Error(w, errorJSON(err), http.StatusBadRequest)
+				return
+			}
+		}
+		from, to := strings.TrimSpace(values.Get("from")), strings.TrimSpace(values.Get("to"))
+		for _, date := range []string{from, to} {
+			if _, err := time.Parse(settings.DateFormat, date); date != "" && err != nil {
+				err := errors.New("invalid date interval, dates must be in format 20060102")
+				http.Error(w, errorJSON(err), http.StatusBadRequest)
+				return
+			}
+		}
+		if from != "" && to != "" && to < from {
+			err := errors.New("end of the interval is earlier than its beginning")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
 
-	if creds.Password != settings.EnvPass {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(Response{Error: "Invalid password"})
-		return
-	}
+		completions, err := store.GetCompletions(id, from, to)
+		if err != nil {
+			log.Printf("Handler GetHistory: id = %v; from = %v; to = %v; err = %v\n", id, from, to, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
 
-	hash := sha256.Sum256([]byte(creds.Password))
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		//"exp":      time.Now().Add(time.Hour * 8).Unix(),
-		"checksum": fmt.Sprintf("%x", hash),
-	})
+		response := map[string][]models.Completion{"history": completions}
+		jsonResponse, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
 
-	tokenString, err := token.SignedString(settings.JwtSecretKey)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(Response{Error: "Failed to generate token"})
-		return
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(jsonResponse)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(Response{Token: tokenString})
 }
 
-// AuthMiddleware обработчик аутентификации пользователя по токену из куки
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// смотрим наличие пароля
-		if len(settings.EnvPass) > 0 {
-			var tokenString string // JWT-токен из куки
-			// получаем куку
-			cookie, err := r.Cookie("token")
-			if err == nil {
-				tokenString = cookie.Value
+// GetTaskRevisions обработчик возвращает историю изменений задачи по ID, начиная с последних изменений,
+// в формате списка JSON с изменениями полей задачи в каждой ревизии
+func GetTaskRevisions(store database.RevisionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		idParam := r.URL.Query().Get("id")
+		if strings.TrimSpace(idParam) == "" {
+			err := errors.New("task ID not specified")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		revisions, err := store.GetRevisions(id)
+		if err != nil {
+			log.Printf("Handler GetTaskRevisions: id = %v; err = %v\n", id, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string][]TaskRevision{"revisions": {}}
+		for _, revision := range revisions {
+			changes, err := revisionChanges(revision)
+			if err != nil {
+				log.Printf("Handler GetTaskRevisions: revision = %v; err = %v\n", revision, err)
+				http.Error(w, errorJSON(err), http.StatusInternalServerError)
+				return
+			}
+			response["revisions"] = append(response["revisions"], TaskRevision{Revision: revision, Changes: changes})
+		}
+		jsonResponse, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(jsonResponse)
+	}
+}
+
+// RevertTask обработчик возвращает задаче по ID значения после изменения ревизией с номером из параметра revision
+func RevertTask(store database.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
+		if r.Method != http.MethodPost {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		values := r.URL.Query()
+		if strings.TrimSpace(values.Get("id")) == "" || strings.TrimSpace(values.Get("revision")) == "" {
+			err := errors.New("task ID or revision not specified")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(values.Get("id"))
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		revision, err := strconv.Atoi(values.Get("revision"))
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		if err := store.RevertTask(id, revision); err != nil {
+			log.Printf("Handler RevertTask: id = %v; revision = %v; error = %v\n", id, revision, err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, database.ErrTaskNotFound) || errors.Is(err, database.ErrRevisionNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, errorJSON(err), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	}
+}
+
+// GetTrash обработчик возвращает страницу задач из корзины, начиная с удаленных последними, в формате списка JSON.
+// Параметры limit и cursor задают размер и начало страницы, ответ содержит next_cursor и total, как в GetTasks
+func GetTrash(store database.TrashRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		query, err := trashQuery(r)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		page, err := store.GetTrash(query)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Handler GetTrash: err = %v\n", err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+		lang := requestLang(r)
+		for i := range page.Tasks {
+			describeRepeat(&page.Tasks[i], lang)
+		}
+
+		response := map[string]any{"tasks": page.Tasks, "next_cursor": page.NextCursor, "total": page.Total}
+		jsonResponse, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(jsonResponse)
+	}
+}
+
+// RestoreTask обработчик восстанавливает задачу из корзины по ID
+func RestoreTask(store database.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
+		if r.Method != http.MethodPost {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		idParam := r.URL.Query().Get("id")
+		if strings.TrimSpace(idParam) == "" {
+			err := errors.New("task ID not specified")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		if err := store.RestoreTask(id); err != nil {
+			log.Printf("Handler RestoreTask: id = %v; error = %v\n", id, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	}
+}
+
+// GetMissed обработчик возвращает последние просроченные повторения задач, записанные в историю
+// по политике "record", в формате списка JSON. При наличии параметра id возвращает повторения одной задачи
+func GetMissed(store database.HistoryRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := 0
+		if idParam := strings.TrimSpace(r.URL.Query().Get("id")); idParam != "" {
+			var err error
+			if id, err = strconv.Atoi(idParam); err != nil {
+				http.Error(w, errorJSON(err), http.StatusBadRequest)
+				return
+			}
+		}
+		missed, err := store.GetHistory(id, models.HistoryMissed)
+		if err != nil {
+			log.Printf("Handler GetMissed: id = %v; err = %v\n", id, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string][]models.HistoryEntry{"missed": missed}
+		jsonResponse, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(jsonResponse)
+	}
+}
+
+// PostTaskException обработчик добавляет в задачу исключение из серии повторений, переданное в json:
+// пропуск повторения либо его перенос на другой день. Если исключение относится к текущему повторению,
+// дата задачи переносится на новый день либо на следующее повторение
+func PostTaskException(store database.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
+		if r.Method != http.MethodPost {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var exception TaskException
+		if err := json.NewDecoder(r.Body).Decode(&exception); err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(exception.ID) == "" {
+			err := errors.New("task ID not specified")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(exception.ID))
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+
+		task, err := store.GetTaskByID(id)
+		if err != nil {
+			log.Printf("Handler PostTaskException: id = %v; task = %v; error = %v\n", id, task, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+		if strings.TrimSpace(task.Repeat) == "" {
+			err := errors.New("exceptions are allowed only for repeating tasks")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		exceptions, err := scheduler.ParseExceptions(task.Exceptions)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		// исключение относится к текущему повторению, если совпадает с его исходным днём
+		current := scheduler.JoinDateTime(task.Date, task.Time)
+		date, to := strings.TrimSpace(exception.Date), strings.TrimSpace(exception.To)
+		origin, _ := scheduler.SplitDateTime(exceptions.Origin(current))
+		if to == "" {
+			err = exceptions.Skip(date)
+		} else {
+			err = exceptions.Move(date, to)
+		}
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		task.Exceptions = exceptions.String()
+
+		if origin == date && to != "" {
+			task.Date = to
+		} else if origin == date {
+			// пропущенное текущее повторение заменяется следующим, количество повторений не уменьшается
+			loc, err := requestLocation(r)
+			if err != nil {
+				http.Error(w, errorJSON(err), http.StatusBadRequest)
+				return
+			}
+			series, err := taskSeries(task, loc)
+			if err != nil {
+				http.Error(w, errorJSON(err), http.StatusInternalServerError)
+				return
+			}
+			nextDate, err := series.NextDate(current)
+			if err != nil {
+				http.Error(w, errorJSON(err), http.StatusInternalServerError)
+				return
+			}
+			if nextDate == "" {
+				err := errors.New("the last occurrence cannot be skipped, delete the task instead")
+				http.Error(w, errorJSON(err), http.StatusBadRequest)
+				return
+			}
+			task.Date, task.Time = scheduler.SplitDateTime(nextDate)
+		}
+
+		if err = store.UpdateTask(task); err != nil {
+			log.Printf("Handler PostTaskException: task = %v; error = %v\n", task, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("{}"))
+	}
+}
+
+// DeleteTaskException обработчик удаляет из задачи с переданным ID исключение для повторения в день date.
+// Если отменяется перенос текущего повторения либо пропуск повторения, предшествующего текущему,
+// дата задачи возвращается на исходный день повторения
+func DeleteTaskException(store database.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithActor(requestActor(r))
+		if r.Method != http.MethodDelete {
+			err := errors.New("method not supported")
+			http.Error(w, errorJSON(err), http.StatusMethodNotAllowed)
+			return
+		}
+
+		idParam := r.URL.Query().Get("id")
+		if strings.TrimSpace(idParam) == "" {
+			err := errors.New("task ID not specified")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		date := strings.TrimSpace(r.URL.Query().Get("date"))
+
+		task, err := store.GetTaskByID(id)
+		if err != nil {
+			log.Printf("Handler DeleteTaskException: id = %v; task = %v; error = %v\n", id, task, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+		exceptions, err := scheduler.ParseExceptions(task.Exceptions)
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+		loc, err := requestLocation(r)
+		if err == nil {
+			loc, err = taskLocation(task, loc)
+		}
+		if err != nil {
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+
+		origin, _ := scheduler.SplitDateTime(exceptions.Origin(scheduler.JoinDateTime(task.Date, task.Time)))
+		skipped := exceptions.Skipped(date)
+		if !exceptions.Remove(date) {
+			err := errors.New("exception not found")
+			http.Error(w, errorJSON(err), http.StatusBadRequest)
+			return
+		}
+		task.Exceptions = exceptions.String()
+
+		today := time.Now().In(loc).Format(settings.DateFormat)
+		if origin == date || (skipped && date >= today && date < task.Date) {
+			task.Date = date
+		}
+
+		if err = store.UpdateTask(task); err != nil {
+			log.Printf("Handler DeleteTaskException: task = %v; error = %v\n", task, err)
+			http.Error(w, errorJSON(err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	}
+}
+
+type Credentials struct {
+	Password string `json:"password"`
+	TimeZone string `json:"tz,omitempty"`   // часовой пояс пользователя, например Europe/Moscow
+	User     string `json:"user,omitempty"` // имя пользователя для истории изменений задач
+}
+
+// userTimeZoneKey - ключ контекста запроса, в котором хранится часовой пояс пользователя из токена
+type userTimeZoneKey struct{}
+
+// userNameKey - ключ контекста запроса, в котором хранится имя пользователя из токена
+type userNameKey struct{}
+
+type Response struct {
+	Token string `json:"token,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// AuthHandler обработчик аутентификации пользователя по паролю
+func AuthHandler(w http.ResponseWriter, r *http.Request) {
+	var creds Credentials
+	err := json.NewDecoder(r.Body).Decode(&creds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{Error: "Invalid request payload"})
+		return
+	}
+
+	if creds.Password != settings.EnvPass {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{Error: "Invalid password"})
+		return
+	}
+
+	hash := sha256.Sum256([]byte(creds.Password))
+	claims := jwt.MapClaims{
+		//"exp":      time.Now().Add(time.Hour * 8).Unix(),
+		"checksum": fmt.Sprintf("%x", hash),
+	}
+	// часовой пояс пользователя сохраняется в токене и используется по умолчанию во всех его запросах
+	if tz := strings.TrimSpace(creds.TimeZone); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(Response{Error: "Invalid time zone"})
+			return
+		}
+		claims["tz"] = tz
+	}
+	// имя пользователя сохраняется в подписанном токене и записывается в историю изменений задач.
+	// Вход выполняется по общему паролю, поэтому имя указывает сам клиент и оно не подтверждает личность пользователя
+	if user := strings.TrimSpace(creds.User); user != "" {
+		if err := validUserName(user); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(Response{Error: err.Error()})
+			return
+		}
+		claims["user"] = user
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	tokenString, err := token.SignedString(settings.JwtSecretKey)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{Error: "Failed to generate token"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(Response{Token: tokenString})
+}
+
+// AuthMiddleware обработчик аутентификации пользователя по токену из куки
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// смотрим наличие пароля
+		if len(settings.EnvPass) > 0 {
+			var tokenString string // JWT-токен из куки
+			// получаем куку
+			cookie, err := r.Cookie("token")
+			if err == nil {
+				tokenString = cookie.Value
 			}
 			// здесь код для валидации и проверки JWT-токена
 			jwtToken, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -445,11 +1186,353 @@ func AuthMiddleware(next http.Handler) http.Handler {
 				http.Error(w, "Authentification required", http.StatusUnauthorized)
 				return
 			}
+			if tz, ok := claims["tz"].(string); ok {
+				r = r.WithContext(context.WithValue(r.Context(), userTimeZoneKey{}, tz))
+			}
+			// имя пользователя принимается только из токена, выданного /api/signin и подписанного ключом сервера
+			if user, ok := claims["user"].(string); ok && validUserName(user) == nil {
+				r = r.WithContext(context.WithValue(r.Context(), userNameKey{}, user))
+			}
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// trashQuery возвращает условия получения страницы корзины из параметров запроса r
+func trashQuery(r *http.Request) (database.TrashQuery, error) {
+	values := r.URL.Query()
+	query := database.TrashQuery{Cursor: values.Get("cursor")}
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > settings.MaxLimit {
+			return query, fmt.Errorf("invalid limit, must be from 1 to %d", settings.MaxLimit)
+		}
+	}
+	return query, nil
+}
+
+// taskQuery возвращает условия получения списка задач из параметров запроса r
+func taskQuery(r *http.Request) (database.TaskQuery, error) {
+	values := r.URL.Query()
+	query := database.TaskQuery{
+		Search:   values.Get("search"),
+		SearchIn: values.Get("in"),
+		From:     strings.TrimSpace(values.Get("from")),
+		To:       strings.TrimSpace(values.Get("to")),
+		Sort:     values.Get("sort"),
+		Cursor:   values.Get("cursor"),
+	}
+	var err error
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > settings.MaxLimit {
+			return query, fmt.Errorf("invalid limit, must be from 1 to %d", settings.MaxLimit)
+		}
+	}
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, errors.New("invalid order, must be 'asc' or 'desc'")
+	}
+	if query.Repeating, err = boolParam(values, "repeating"); err != nil {
+		return query, err
+	}
+	if query.Overdue, err = boolParam(values, "overdue"); err != nil {
+		return query, err
+	}
+	if query.Overdue != nil {
+		loc, err := requestLocation(r)
+		if err != nil {
+			return query, err
+		}
+		query.Now = time.Now().In(loc).Format(settings.DateTimeFormat)
+	}
+	return query, query.Validate()
+}
+
+// boolParam возвращает значение логического параметра name из параметров запроса values; nil - параметр не задан
+func boolParam(values url.Values, name string) (*bool, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, must be 'true' or 'false'", name)
+	}
+	return &flag, nil
+}
+
+// requestLang возвращает язык описаний для запроса r: из параметра lang,
+// либо из заголовка Accept-Language, по умолчанию - русский
+func requestLang(r *http.Request) string {
+	lang := r.FormValue("lang")
+	if lang == "" {
+		lang = r.Header.Get("Accept-Language")
+	}
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if strings.HasPrefix(lang, scheduler.LangEN) {
+		return scheduler.LangEN
+	}
+	return scheduler.LangRU
+}
+
+// requestLocation возвращает часовой пояс запроса r: из параметра tz, из токена пользователя,
+// либо часовой пояс сервера по умолчанию
+func requestLocation(r *http.Request) (*time.Location, error) {
+	name := strings.TrimSpace(r.URL.Query().Get("tz"))
+	if name == "" {
+		name, _ = r.Context().Value(userTimeZoneKey{}).(string)
+	}
+	if name == "" {
+		return scheduler.Location(), nil
+	}
+	return time.LoadLocation(name)
+}
+
+// validUserName проверяет имя пользователя user из запроса входа: не длиннее settings.MaxUserName символов,
+// только буквы, цифры, пробелы и символы . _ @ -
+func validUserName(user string) error {
+	if !utf8.ValidString(user) || utf8.RuneCountInString(user) > settings.MaxUserName {
+		return fmt.Errorf("user name must be at most %d characters", settings.MaxUserName)
+	}
+	for _, r := range user {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" ._@-", r) {
+			return fmt.Errorf("invalid character %q in user name", r)
+		}
+	}
+	return nil
+}
+
+// requestActor возвращает имя пользователя запроса r из токена, от имени которого записываются изменения задач;
+// "" - пользователь не указан
+func requestActor(r *http.Request) string {
+	user, _ := r.Context().Value(userNameKey{}).(string)
+	return user
+}
+
+// taskLocation возвращает часовой пояс задачи task, либо часовой пояс loc, если у задачи он не задан
+func taskLocation(task models.Task, loc *time.Location) (*time.Location, error) {
+	if task.TimeZone == "" {
+		return loc, nil
+	}
+	return time.LoadLocation(task.TimeZone)
+}
+
+// describeRepeat заполняет описание правила повторения задачи task на языке lang и, если правило можно
+// выразить в формате iCalendar, его запись RRULE для экспорта в календари
+func describeRepeat(task *models.Task, lang string) {
+	description, err := scheduler.Describe(task.Repeat, lang)
+	if err != nil {
+		log.Printf("describeRepeat: task = %v; error = %v\n", task, err)
+		return
+	}
+	task.RepeatText = description
+	if task.Repeat != "" {
+		// правила с модификаторами рабочих дней и високосного года не имеют записи RRULE
+		task.RRule, _ = scheduler.ToRRULE(task.Repeat, task.RepeatCount, task.RepeatUntil)
+	}
+}
+
+// convertRRULE преобразует правило повторения задачи task, заданное в формате iCalendar RRULE,
+// в правило повторения задач сервера. Условия окончания COUNT и UNTIL переносятся в поля задачи.
+// Датой начала правила (DTSTART) считается дата задачи, а для задачи без даты - текущая дата запроса r
+func convertRRULE(task *models.Task, r *http.Request) error {
+	if !scheduler.IsRRULE(task.Repeat) {
+		return nil
+	}
+
+	// ошибки часового пояса возвращает adjustTaskDate
+	start := time.Now()
+	if loc, err := requestLocation(r); err == nil {
+		if loc, err = taskLocation(*task, loc); err == nil {
+			start = start.In(loc)
+		}
+	}
+	if date, _ := scheduler.SplitDateTime(task.Date); date != "" {
+		parsed, err := time.Parse(settings.DateFormat, date)
+		if err != nil {
+			return err
+		}
+		start = parsed
+	}
+	repeat, count, until, err := scheduler.FromRRULE(task.Repeat, start)
+	if err != nil {
+		return err
+	}
+	task.Repeat = repeat
+	if count > 0 {
+		task.RepeatCount = count
+	}
+	if until != "" {
+		task.RepeatUntil = until
+	}
+	return nil
+}
+
+// adjustTaskDate проверяет дату, время, часовой пояс и правило повторения задачи task, переданной в запросе r,
+// и корректирует их при необходимости: пустая дата заменяется текущей, а прошедшая - текущей для задач
+// без повторения либо ближайшей датой повторения для повторяющихся задач
+func adjustTaskDate(task *models.Task, r *http.Request) error {
+	loc, err := requestLocation(r)
+	if err != nil {
+		return err
+	}
+	task.TimeZone = strings.TrimSpace(task.TimeZone)
+	if loc, err = taskLocation(*task, loc); err != nil {
+		return err
+	}
+
+	now := time.Now().In(loc)
+	today, nowTime := now.Format(settings.DateFormat), now.Format(settings.TimeFormat)
+
+	task.Date, task.Time = strings.TrimSpace(task.Date), strings.TrimSpace(task.Time)
+	if task.Time == "" { // дата может быть передана вместе со временем в формате "20060102 15:04"
+		task.Date, task.Time = scheduler.SplitDateTime(task.Date)
+	}
+	if task.Date == "" {
+		task.Date = today
+	}
+	if _, err := time.Parse(settings.DateFormat, task.Date); err != nil {
+		return err
+	}
+	// повторения по часам и минутам без указанного времени отсчитываются от текущего времени
+	if task.Time == "" && scheduler.IsTimeRule(task.Repeat) {
+		task.Time = nowTime
+	}
+	if task.Time != "" {
+		if _, err := time.Parse(settings.TimeFormat, task.Time); err != nil {
+			return err
+		}
+	}
+
+	date := scheduler.JoinDateTime(task.Date, task.Time)
+	nextDate := ""
+	if strings.TrimSpace(task.Repeat) != "" {
+		nextDate, err = scheduler.NextDateIn(scheduler.JoinDateTime(today, nowTime), date, task.Repeat, loc)
+		if err != nil {
+			return err
+		}
+	}
+
+	// задача на весь день считается прошедшей с начала своей даты, задача со временем - с наступления этого времени
+	past := task.Date <= today
+	if task.Time != "" {
+		past = date < scheduler.JoinDateTime(today, nowTime)
+	}
+	if past {
+		if nextDate == "" {
+			task.Date = today
+		} else {
+			task.Date, task.Time = scheduler.SplitDateTime(nextDate)
+		}
+	}
+	return nil
+}
+
+// missedEntries возвращает записи истории о пропущенных повторениях задачи task с датами dates
+func missedEntries(task models.Task, dates []string) []models.HistoryEntry {
+	entries := make([]models.HistoryEntry, 0, len(dates))
+	for _, date := range dates {
+		entry := models.HistoryEntry{TaskID: task.ID, Title: task.Title, Status: models.HistoryMissed}
+		entry.Date, entry.Time = scheduler.SplitDateTime(date)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// newCompletion возвращает запись истории выполнения задачи task в текущее время в её часовом поясе,
+// а если он не задан - в часовом поясе запроса loc
+func newCompletion(task models.Task, loc *time.Location) (models.Completion, error) {
+	loc, err := taskLocation(task, loc)
+	if err != nil {
+		return models.Completion{}, err
+	}
+	return models.Completion{TaskID: task.ID, Date: task.Date, Time: task.Time,
+		CompletedAt: time.Now().In(loc).Format(settings.DateTimeFormat), Title: task.Title, Comment: task.Comment, Repeat: task.Repeat}, nil
+}
+
+// revisionChanges возвращает изменения полей задачи ревизией revision, кроме id, в порядке полей models.Task
+func revisionChanges(revision models.Revision) ([]FieldChange, error) {
+	var old, task models.Task
+	if revision.OldTask != "" {
+		if err := json.Unmarshal([]byte(revision.OldTask), &old); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal([]byte(revision.NewTask), &task); err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(task)
+	for i := 0; i < oldValue.NumField(); i++ {
+		field, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("json"), ",")
+		before, after := oldValue.Field(i).Interface(), newValue.Field(i).Interface()
+		if field != "id" && before != after {
+			changes = append(changes, FieldChange{Field: field, Old: before, New: after})
+		}
+	}
+	return changes, nil
+}
+
+// taskSeries возвращает серию повторений задачи task в её часовом поясе,
+// а если он не задан - в часовом поясе запроса loc
+func taskSeries(task models.Task, loc *time.Location) (scheduler.Series, error) {
+	loc, err := taskLocation(task, loc)
+	if err != nil {
+		return scheduler.Series{}, err
+	}
+	return scheduler.Series{
+		Date:   scheduler.JoinDateTime(task.Date, task.Time),
+		Repeat: task.Repeat,
+		Count:  task.RepeatCount,
+		Until:  task.RepeatUntil,
+		From:   task.RepeatFrom,
+
+		Overdue:    task.Overdue,
+		Exceptions: task.Exceptions,
+		Location:   loc,
+	}, nil
+}
+
+// checkRepeatEnd проверяет режим отсчёта, политику для просроченных повторений, исключения и условия окончания повторений задачи task.
+// Для задачи без правила повторения условия окончания и исключения сбрасываются
+func checkRepeatEnd(task *models.Task) error {
+	from, err := scheduler.CheckRepeatFrom(task.RepeatFrom)
+	if err != nil {
+		return err
+	}
+	task.RepeatFrom = from
+
+	overdue, err := scheduler.CheckOverdue(task.Overdue)
+	if err != nil {
+		return err
+	}
+	task.Overdue = overdue
+
+	if strings.TrimSpace(task.Repeat) == "" {
+		task.RepeatCount, task.RepeatUntil, task.Exceptions = 0, "", ""
+		return nil
+	}
+
+	exceptions, err := scheduler.ParseExceptions(task.Exceptions)
+	if err != nil {
+		return err
+	}
+	task.Exceptions = exceptions.String()
+
+	task.RepeatUntil = strings.TrimSpace(task.RepeatUntil)
+	if err := scheduler.CheckRepeatEnd(task.RepeatCount, task.RepeatUntil); err != nil {
+		return err
+	}
+	if task.RepeatUntil != "" && task.Date > task.RepeatUntil {
+		return errors.New("task date is later than the repeat end date")
+	}
+	return nil
+}
+
 // errorJSON возвращает json-строку с ошибкой
 func errorJSON(err error) string {
 	jsonError, err := json.Marshal(map[string]string{"error": err.Error()})
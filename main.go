@@ -4,11 +4,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata" // база часовых поясов для образов без zoneinfo
 
+	_ "github.com/lib/pq"
 	_ "modernc.org/sqlite"
 
 	"github.com/FausT-VX/todo-list-server/database"
 	"github.com/FausT-VX/todo-list-server/handlers"
+	"github.com/FausT-VX/todo-list-server/service/calendar"
+	"github.com/FausT-VX/todo-list-server/service/scheduler"
+	"github.com/FausT-VX/todo-list-server/service/trash"
 	"github.com/FausT-VX/todo-list-server/settings"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/v5/middleware"
@@ -17,16 +23,75 @@ import (
 func main() {
 	infLog := log.New(os.Stdout, "todo-server INF: ", log.Ldate|log.Ltime)
 	errLog := log.New(os.Stderr, "todo-server ERR: ", log.Ldate|log.Ltime)
-	infLog.Println("Starting application...")
 
-	// Соединение с базой данных
-	db, err := database.ConnectDB(settings.DBPath)
-	if err != nil {
-		errLog.Println(err)
+	// Подкоманда migrate: просмотр и применение миграций базы данных без запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:], os.Stdout); err != nil {
+			errLog.Println(err)
+			os.Exit(1)
+		}
 		return
 	}
-	defer db.Close()
-	store := database.NewTasksStore(db)
+
+	infLog.Println("Starting application...")
+
+	// Загрузка производственного календаря для правил повторения по рабочим дням
+	if settings.EnvCalendar != "" {
+		cal, err := calendar.Load(settings.EnvCalendar)
+		if err != nil {
+			errLog.Println(err)
+			return
+		}
+		scheduler.SetCalendar(cal)
+		infLog.Printf("Calendar %s has been loaded\n", settings.EnvCalendar)
+	}
+
+	// Часовой пояс по умолчанию для вычисления дат повторений задач
+	if settings.EnvTZ != "" {
+		loc, err := time.LoadLocation(settings.EnvTZ)
+		if err != nil {
+			errLog.Println(err)
+			return
+		}
+		scheduler.SetLocation(loc)
+		infLog.Printf("Default time zone is %s\n", loc)
+	}
+
+	// Соединение с базой данных: PostgreSQL, если задана строка подключения, иначе SQLite
+	var store database.Repository
+	if settings.EnvDSN != "" {
+		db, err := database.ConnectPostgres(settings.EnvDSN)
+		if err != nil {
+			errLog.Println(err)
+			return
+		}
+		defer db.Close()
+		store = database.NewPostgresStore(db)
+		infLog.Println("Using PostgreSQL database")
+	} else {
+		db, err := database.ConnectDB(settings.DBPath)
+		if err != nil {
+			errLog.Println(err)
+			return
+		}
+		defer db.Close()
+		store = database.NewTasksStore(db)
+	}
+
+	// Окончательное удаление задач, хранящихся в корзине дольше срока хранения
+	retention := settings.TrashRetention
+	if settings.EnvTrashRetention != "" {
+		var err error
+		if retention, err = time.ParseDuration(settings.EnvTrashRetention); err != nil {
+			errLog.Println(err)
+			return
+		}
+	}
+	if retention > 0 {
+		go trash.Run(store, retention, settings.TrashPurgeInterval, infLog)
+		infLog.Printf("Deleted tasks are kept in the trash for %s\n", retention)
+	}
+
 	// инициализация маршрутизатора
 	router := chi.NewRouter()
 
@@ -42,12 +107,26 @@ func main() {
 	apiRouter.Use(middleware.Recoverer)
 	apiRouter.Use(handlers.AuthMiddleware)
 	apiRouter.Get("/tasks", handlers.GetTasks(store))
+	apiRouter.Get("/occurrences", handlers.GetOccurrences(store))
+	apiRouter.Get("/missed", handlers.GetMissed(store))
+	apiRouter.Get("/history", handlers.GetHistory(store))
+	apiRouter.Get("/trash", handlers.GetTrash(store))
 	apiRouter.Route("/task", func(r chi.Router) {
 		r.Get("/", handlers.GetTaskByID(store))
 		r.Post("/", handlers.PostTask(store))
 		r.Post("/done", handlers.PostTaskDone(store))
 		r.Put("/", handlers.PutTask(store))
 		r.Delete("/", handlers.DeleteTask(store))
+		r.Post("/restore", handlers.RestoreTask(store))
+		r.Get("/history", handlers.GetTaskRevisions(store))
+		r.Post("/revert", handlers.RevertTask(store))
+		r.Post("/exception", handlers.PostTaskException(store))
+		r.Delete("/exception", handlers.DeleteTaskException(store))
+	})
+	apiRouter.Route("/repeat", func(r chi.Router) {
+		r.Get("/describe", handlers.DescribeRepeatHandler)
+		r.Post("/validate", handlers.ValidateRepeatHandler)
+		r.Get("/rules", handlers.RepeatRulesHandler)
 	})
 	router.Mount("/api", apiRouter)
 	router.Post("/api/signin", handlers.AuthHandler)
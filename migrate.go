@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/FausT-VX/todo-list-server/database"
+	"github.com/FausT-VX/todo-list-server/database/migrations"
+	"github.com/FausT-VX/todo-list-server/settings"
+	"github.com/jmoiron/sqlx"
+)
+
+// migrateUsage - справка по подкоманде migrate
+const migrateUsage = `Usage: todo_server migrate [status | up [-dry-run]]
+  status    show the schema version and the state of every migration (default)
+  up        apply pending migrations
+  -dry-run  apply pending migrations in a transaction and roll it back`
+
+// runMigrate выполняет подкоманду migrate с аргументами args и выводит результат в out:
+// status - версия структуры базы данных и состояние миграций, up - применение новых миграций
+func runMigrate(args []string, out io.Writer) error {
+	command := "status"
+	if len(args) > 0 && (args[0] == "status" || args[0] == "up") {
+		command, args = args[0], args[1:]
+	}
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() { fmt.Fprintln(out, migrateUsage) }
+	dryRun := flags.Bool("dry-run", false, "apply pending migrations in a transaction and roll it back")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 || command == "status" && *dryRun {
+		flags.Usage()
+		return errors.New("invalid migrate arguments")
+	}
+
+	var db *sqlx.DB
+	var err error
+	if settings.EnvDSN != "" {
+		db, err = database.OpenPostgres(settings.EnvDSN)
+	} else {
+		db, err = database.OpenDB(settings.DBPath)
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if command == "up" {
+		applied, err := migrations.Up(db, *dryRun)
+		for _, migration := range applied {
+			if *dryRun {
+				fmt.Fprintf(out, "%04d_%s can be applied\n", migration.Version, migration.Name)
+			} else {
+				fmt.Fprintf(out, "%04d_%s applied\n", migration.Version, migration.Name)
+			}
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "No pending migrations")
+		}
+		return err
+	}
+
+	current, err := migrations.Current(db)
+	if err != nil {
+		return err
+	}
+	statuses, err := migrations.Statuses(db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Schema version: %d\n", current)
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != "" {
+			state = "applied " + status.AppliedAt
+		}
+		fmt.Fprintf(out, "%04d_%-24s %s\n", status.Version, status.Name, state)
+	}
+	return nil
+}
@@ -2,9 +2,70 @@
 package models
 
 type Task struct {
-	ID      string `json:"id"      db:"id,omitempty"`
-	Date    string `json:"date"    db:"date"`
-	Title   string `json:"title"   db:"title"`
-	Comment string `json:"comment" db:"comment"`
-	Repeat  string `json:"repeat"  db:"repeat"`
+	ID          string `json:"id"                     db:"id,omitempty"`
+	Date        string `json:"date"                   db:"date"`
+	Time        string `json:"time,omitempty"         db:"time"` // время задачи в формате 15:04, "" - на весь день
+	Title       string `json:"title"                  db:"title"`
+	Comment     string `json:"comment"                db:"comment"`
+	Repeat      string `json:"repeat"                 db:"repeat"`
+	RepeatCount int    `json:"repeat_count,omitempty" db:"repeat_count"` // оставшееся количество повторений, 0 - без ограничений
+	RepeatUntil string `json:"repeat_until,omitempty" db:"repeat_until"` // дата окончания повторений, "" - без ограничений
+	RepeatFrom  string `json:"repeat_from,omitempty"  db:"repeat_from"`  // режим отсчёта повторений: "schedule" или "completion"
+	Overdue     string `json:"overdue,omitempty"      db:"overdue"`      // политика для просроченных повторений: "skip", "step" или "record"
+	Exceptions  string `json:"exceptions,omitempty"   db:"exceptions"`   // пропущенные и перенесённые повторения: "20240105,20240112>20240113"
+	TimeZone    string `json:"tz,omitempty"           db:"tz"`           // часовой пояс задачи, например Europe/Moscow; "" - пояс пользователя
+	RepeatText  string `json:"repeat_text,omitempty"  db:"-"`            // описание правила повторения, в БД не хранится
+	RRule       string `json:"rrule,omitempty"        db:"-"`            // правило повторения в формате iCalendar RRULE, в БД не хранится
+	Snippet     string `json:"snippet,omitempty"      db:"snippet"`      // фрагмент с выделенными совпадениями, только в результатах поиска
+	DeletedAt   string `json:"deleted_at,omitempty"   db:"deleted_at"`   // время удаления в корзину в формате "20060102 15:04" UTC; "" - задача не удалена
+	CompletedAt string `json:"completed_at,omitempty" db:"completed_at"` // время выполнения разовой задачи в часовом поясе задачи; "" - не выполнена
+}
+
+// Статусы записей истории повторений задачи
+const (
+	HistoryMissed = "missed" // повторение просрочено и пропущено при выполнении задачи
+)
+
+// HistoryEntry - запись истории повторений задачи
+type HistoryEntry struct {
+	ID     string `json:"id"             db:"id,omitempty"`
+	TaskID string `json:"task_id"        db:"task_id"`
+	Date   string `json:"date"           db:"date"`
+	Time   string `json:"time,omitempty" db:"time"`
+	Title  string `json:"title"          db:"title"` // заголовок задачи на момент записи
+	Status string `json:"status"         db:"status"`
+}
+
+// Completion - запись истории выполнения задачи с копией задачи на момент выполнения
+type Completion struct {
+	ID          string `json:"id"             db:"id,omitempty"`
+	TaskID      string `json:"task_id"        db:"task_id"`
+	Date        string `json:"date"           db:"date"`         // дата выполненного повторения по расписанию
+	Time        string `json:"time,omitempty" db:"time"`         // время выполненного повторения по расписанию, "" - на весь день
+	CompletedAt string `json:"completed_at"   db:"completed_at"` // время выполнения в формате "20060102 15:04" в часовом поясе задачи
+	Title       string `json:"title"          db:"title"`
+	Comment     string `json:"comment"        db:"comment"`
+	Repeat      string `json:"repeat"         db:"repeat"`
+}
+
+// Действия, записываемые в историю изменений задачи
+const (
+	RevisionInsert  = "insert"  // задача добавлена
+	RevisionUpdate  = "update"  // задача изменена
+	RevisionDelete  = "delete"  // задача удалена в корзину
+	RevisionRestore = "restore" // задача восстановлена из корзины
+	RevisionRevert  = "revert"  // задаче возвращены значения одной из предыдущих ревизий
+	RevisionPurge   = "purge"   // задача окончательно удалена из корзины
+	RevisionDone    = "done"    // задача выполнена: перенесена на следующее повторение либо отмечена выполненной
+)
+
+// Revision - запись истории изменений задачи
+type Revision struct {
+	ID        string `json:"id"              db:"id,omitempty"`
+	TaskID    string `json:"task_id"         db:"task_id"`
+	Action    string `json:"action"          db:"action"`
+	ChangedAt string `json:"changed_at"      db:"changed_at"` // время изменения в формате "20060102 15:04" UTC
+	Actor     string `json:"actor,omitempty" db:"actor"`      // пользователь из токена авторизации, "" - не указан
+	OldTask   string `json:"-"               db:"old_task"`   // задача до изменения в формате JSON, "" - задача добавлена
+	NewTask   string `json:"-"               db:"new_task"`   // задача после изменения в формате JSON
 }
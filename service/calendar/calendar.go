@@ -0,0 +1,171 @@
+package calendar
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// Calendar - производственный календарь.
+// Для годов, загруженных из производственного календаря в формате JSON, нерабочими считаются только
+// перечисленные в нём дни; для остальных годов - суббота, воскресенье и праздники из файла ICS
+type Calendar struct {
+	offDays map[string]bool // нерабочие дни в формате 20060102
+	years   map[int]bool    // годы, для которых известны все нерабочие дни
+}
+
+// New создает календарь, в котором нерабочими днями являются только суббота и воскресенье
+func New() *Calendar {
+	return &Calendar{offDays: map[string]bool{}, years: map[int]bool{}}
+}
+
+// Load загружает календарь из файла path. Формат файла определяется по расширению:
+// .json - производственный календарь (формат xmlcalendar.ru), .ics - список праздников iCalendar
+func Load(path string) (*Calendar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	c := New()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = c.ReadJSON(file)
+	case ".ics":
+		err = c.ReadICS(file)
+	default:
+		err = errors.New("unsupported calendar file format " + filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// IsWorkday определяет, является ли дата date рабочим днём
+func (c *Calendar) IsWorkday(date time.Time) bool {
+	if c.offDays[date.Format(settings.DateFormat)] {
+		return false
+	}
+	if c.years[date.Year()] {
+		return true
+	}
+	return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+}
+
+// AddHoliday добавляет в календарь нерабочий день date
+func (c *Calendar) AddHoliday(date time.Time) {
+	c.offDays[date.Format(settings.DateFormat)] = true
+}
+
+// productionCalendar - производственный календарь на год в формате xmlcalendar.ru.
+// В поле days перечислены все нерабочие дни месяца; дни с суффиксом "*" - сокращённые рабочие,
+// с суффиксом "+" - перенесённые выходные
+type productionCalendar struct {
+	Year   int `json:"year"`
+	Months []struct {
+		Month int    `json:"month"`
+		Days  string `json:"days"`
+	} `json:"months"`
+}
+
+// ReadJSON читает производственный календарь в формате JSON: один год или массив годов
+func (c *Calendar) ReadJSON(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var years []productionCalendar
+	if err = json.Unmarshal(data, &years); err != nil {
+		var year productionCalendar
+		if err = json.Unmarshal(data, &year); err != nil {
+			return err
+		}
+		years = append(years, year)
+	}
+
+	for _, year := range years {
+		if year.Year < 1 {
+			return errors.New("production calendar year is not specified")
+		}
+		for _, month := range year.Months {
+			if month.Month < 1 || month.Month > 12 {
+				return fmt.Errorf("invalid month %d in production calendar for %d", month.Month, year.Year)
+			}
+			for _, day := range strings.Split(month.Days, ",") {
+				day = strings.TrimSpace(day)
+				if day == "" || strings.HasSuffix(day, "*") { // сокращённый день является рабочим
+					continue
+				}
+				num, err := strconv.Atoi(strings.TrimSuffix(day, "+"))
+				if err != nil {
+					return fmt.Errorf("invalid day %q in production calendar for %d", day, year.Year)
+				}
+				c.AddHoliday(time.Date(year.Year, time.Month(month.Month), num, 0, 0, 0, 0, time.UTC))
+			}
+		}
+		c.years[year.Year] = true
+	}
+	return nil
+}
+
+// ReadICS читает праздники из файла iCalendar: каждое событие VEVENT с датой DTSTART
+// (и необязательной датой окончания DTEND) считается нерабочим днём
+func (c *Calendar) ReadICS(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	var start, end time.Time
+	inEvent := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		name, value, _ := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(name, ";") // отбрасываем параметры свойства, например VALUE=DATE
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent = true
+				start, end = time.Time{}, time.Time{}
+			}
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			if len(value) < len(settings.DateFormat) {
+				return fmt.Errorf("invalid date %q in calendar", value)
+			}
+			date, err := time.Parse(settings.DateFormat, value[:len(settings.DateFormat)])
+			if err != nil {
+				return err
+			}
+			if strings.EqualFold(name, "DTSTART") {
+				start = date
+			} else {
+				end = date
+			}
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return errors.New("calendar event without DTSTART")
+			}
+			// DTEND не входит в событие, однодневное событие может не иметь DTEND
+			c.AddHoliday(start)
+			for date := start.AddDate(0, 0, 1); date.Before(end); date = date.AddDate(0, 0, 1) {
+				c.AddHoliday(date)
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,399 @@
+package scheduler
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Языки описания правил повторения
+const (
+	LangRU = "ru"
+	LangEN = "en"
+)
+
+// Названия месяцев: в английском языке и в родительном падеже в русском
+var (
+	monthsEN = []string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+	monthsRU = []string{"января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря"}
+)
+
+// Названия дней недели, индекс соответствует номеру дня недели в правилах минус 1
+var (
+	weekdaysEN = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+	// в дательном падеже множественного числа: "по понедельникам"
+	weekdaysDatRU = []string{"понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам", "воскресеньям"}
+	// в винительном падеже: "в первый понедельник"
+	weekdaysAccRU = []string{"понедельник", "вторник", "среду", "четверг", "пятницу", "субботу", "воскресенье"}
+	// род названий дней недели: 0 - мужской, 1 - женский, 2 - средний
+	weekdaysGenderRU = []int{0, 0, 1, 0, 1, 1, 2}
+)
+
+// Порядковые числительные в винительном падеже для мужского, женского и среднего рода
+var ordinalsRU = [][]string{
+	{"первый", "первую", "первое"},
+	{"второй", "вторую", "второе"},
+	{"третий", "третью", "третье"},
+	{"четвёртый", "четвёртую", "четвёртое"},
+	{"пятый", "пятую", "пятое"},
+}
+
+var ordinalsEN = []string{"first", "second", "third", "fourth", "fifth"}
+
+// Describe возвращает описание правила повторения repeat на языке lang (LangRU или LangEN)
+func Describe(repeat string, lang string) (string, error) {
+	rules, err := parseRepeat(repeat)
+	if err != nil {
+		return "", err
+	}
+	if rules.datePart == "" {
+		return "", nil
+	}
+	switch strings.ToLower(lang) {
+	case LangRU, "":
+		return rules.rule.Describe(rules, LangRU) + shiftRU[rules.shift+1], nil
+	case LangEN:
+		return rules.rule.Describe(rules, LangEN) + shiftEN[rules.shift+1], nil
+	}
+	return "", errors.New("unsupported description language " + lang)
+}
+
+// Описания переноса дат с нерабочих дней, индекс соответствует значению RepeatRules.shift плюс 1
+var (
+	shiftEN = []string{", moved to the previous working day if it falls on a day off", "",
+		", moved to the next working day if it falls on a day off"}
+	shiftRU = []string{", с переносом на предыдущий рабочий день, если выпадает на выходной", "",
+		", с переносом на следующий рабочий день, если выпадает на выходной"}
+)
+
+// Describe возвращает описание правила "d"
+func (dayRule) Describe(r RepeatRules, lang string) string {
+	if lang == LangEN {
+		day := "day"
+		if r.businessDays {
+			day = "business day"
+		}
+		if r.nums[0][0] == 1 {
+			return "every " + day
+		}
+		return "every " + strconv.Itoa(r.nums[0][0]) + " " + day + "s"
+	}
+	days := []string{"день", "дня", "дней"}
+	if r.businessDays {
+		days = []string{"рабочий день", "рабочих дня", "рабочих дней"}
+	}
+	return everyRU(r.nums[0][0], "каждый", days)
+}
+
+// Describe возвращает описание правил "h" и "min"
+func (t timeRule) Describe(r RepeatRules, lang string) string {
+	if lang == LangEN {
+		unit := map[string]string{"h": "hour", "min": "minute"}[t.name]
+		if r.nums[0][0] == 1 {
+			return "every " + unit
+		}
+		return "every " + strconv.Itoa(r.nums[0][0]) + " " + unit + "s"
+	}
+	if t.name == "h" {
+		return everyRU(r.nums[0][0], "каждый", []string{"час", "часа", "часов"})
+	}
+	return everyRU(r.nums[0][0], "каждую", []string{"минуту", "минуты", "минут"})
+}
+
+// Describe возвращает описание правила "y"
+func (yearRule) Describe(r RepeatRules, lang string) string {
+	if lang == LangEN {
+		every := "every year"
+		if r.every > 1 {
+			every = "every " + strconv.Itoa(r.every) + " years"
+		}
+		if len(r.yearDates) > 0 {
+			dates := make([]string, 0, len(r.yearDates))
+			for _, date := range r.sortedYearDates() {
+				switch date[0] {
+				case -1:
+					dates = append(dates, "the last day of "+monthsEN[date[1]-1])
+				case -2:
+					dates = append(dates, "the second to last day of "+monthsEN[date[1]-1])
+				default:
+					dates = append(dates, monthsEN[date[1]-1]+" "+strconv.Itoa(date[0]))
+				}
+			}
+			every += " on " + joinWords(dates, "and")
+		}
+		return every + r.leapDayEN()
+	}
+	every := "каждый год"
+	if r.every > 1 {
+		every = onceInRU(r.every, []string{"год", "года", "лет"})
+	}
+	if len(r.yearDates) > 0 {
+		dates := make([]string, 0, len(r.yearDates))
+		for _, date := range r.sortedYearDates() {
+			switch date[0] {
+			case -1:
+				dates = append(dates, "в последний день "+monthsRU[date[1]-1])
+			case -2:
+				dates = append(dates, "в предпоследний день "+monthsRU[date[1]-1])
+			default:
+				dates = append(dates, strconv.Itoa(date[0])+" "+monthsRU[date[1]-1])
+			}
+		}
+		every += " " + joinWords(dates, "и")
+	}
+	return every + r.leapDayRU()
+}
+
+// Describe возвращает описание правила "w"
+func (weekRule) Describe(r RepeatRules, lang string) string {
+	if lang == LangEN {
+		weekdays := joinWords(mapNums(sortedUnique(r.nums[0]), weekdayNameEN), "and")
+		if r.every > 1 {
+			return "every " + strconv.Itoa(r.every) + " weeks on " + weekdays
+		}
+		return "every " + weekdays
+	}
+	weekdays := "по " + joinWords(mapNums(sortedUnique(r.nums[0]), func(wd int) string { return weekdaysDatRU[wd-1] }), "и")
+	if r.every > 1 {
+		return weekdays + " " + onceInRU(r.every, []string{"неделю", "недели", "недель"})
+	}
+	return weekdays
+}
+
+// Describe возвращает описание правила "m"
+func (monthRule) Describe(r RepeatRules, lang string) string {
+	if lang == LangEN {
+		days := mapNums(sortedDays(r.nums[0]), func(day int) string {
+			switch day {
+			case -1:
+				return "last day"
+			case -2:
+				return "second to last day"
+			}
+			return strconv.Itoa(day) + englishSuffix(day)
+		})
+		return joinWords(days, "and") + " of " + r.monthsEN(1)
+	}
+	days := mapNums(sortedDays(r.nums[0]), func(day int) string {
+		switch day {
+		case -1:
+			return "последнего"
+		case -2:
+			return "предпоследнего"
+		}
+		return strconv.Itoa(day) + "-го"
+	})
+	return joinWords(days, "и") + " числа " + r.monthsRU(1)
+}
+
+// Describe возвращает описание правила "n"
+func (weekdayRule) Describe(r RepeatRules, lang string) string {
+	if lang == LangEN {
+		ordinals := mapNums(sortedDays(r.nums[0]), ordinalEN)
+		weekdays := mapNums(sortedUnique(r.nums[1]), weekdayNameEN)
+		return "the " + joinWords(ordinals, "and") + " " + joinWords(weekdays, "and") + " of " + r.monthsEN(2)
+	}
+	ordinals := sortedDays(r.nums[0])
+	var phrases []string
+	for _, wd := range sortedUnique(r.nums[1]) {
+		gender := weekdaysGenderRU[wd-1]
+		words := mapNums(ordinals, func(n int) string { return ordinalRU(n, gender) })
+		phrase := joinWords(words, "и") + " " + weekdaysAccRU[wd-1]
+		if strings.HasPrefix(phrase, "вт") {
+			phrases = append(phrases, "во "+phrase)
+		} else {
+			phrases = append(phrases, "в "+phrase)
+		}
+	}
+	return joinWords(phrases, "и") + " " + r.monthsRU(2)
+}
+
+// sortedYearDates возвращает даты правила "y" без повторов, упорядоченные по месяцам и дням;
+// дни, отсчитываемые с конца месяца, идут после остальных
+func (r RepeatRules) sortedYearDates() [][2]int {
+	dates := slices.Clone(r.yearDates)
+	slices.SortFunc(dates, func(a, b [2]int) int {
+		if a[1] != b[1] {
+			return cmp.Compare(a[1], b[1])
+		}
+		return cmp.Compare(uint(a[0]), uint(b[0])) // отрицательные дни становятся больше положительных
+	})
+	return slices.Compact(dates)
+}
+
+// hasLeapDay определяет, может ли правило "y" выпасть на 29 февраля
+func (r RepeatRules) hasLeapDay() bool {
+	return slices.Contains(r.yearDates, [2]int{29, 2}) || len(r.yearDates) == 0 && r.leapDay != ""
+}
+
+// leapDayEN возвращает описание замены 29 февраля в невисокосные годы на английском языке
+func (r RepeatRules) leapDayEN() string {
+	if !r.hasLeapDay() {
+		return ""
+	}
+	if r.leapDay == ModFeb28 {
+		return ", on February 28 in non-leap years"
+	}
+	return ", on March 1 in non-leap years"
+}
+
+// leapDayRU возвращает описание замены 29 февраля в невисокосные годы на русском языке
+func (r RepeatRules) leapDayRU() string {
+	if !r.hasLeapDay() {
+		return ""
+	}
+	if r.leapDay == ModFeb28 {
+		return ", в невисокосные годы 28 февраля"
+	}
+	return ", в невисокосные годы 1 марта"
+}
+
+// everyRU возвращает описание интервала из n единиц на русском языке: "каждый день", "каждые 3 дня".
+// each - форма слова "каждый" в роде единицы, forms - формы единицы для чисел 1, 2-4 и 5-20
+func everyRU(n int, each string, forms []string) string {
+	switch {
+	case n == 1:
+		return each + " " + forms[0]
+	case n%10 == 1 && n%100 != 11:
+		return each + " " + strconv.Itoa(n) + " " + forms[0]
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "каждые " + strconv.Itoa(n) + " " + forms[1]
+	}
+	return "каждые " + strconv.Itoa(n) + " " + forms[2]
+}
+
+// onceInRU возвращает описание интервала из n периодов на русском языке: "раз в 2 недели", "раз в 5 лет".
+// forms - формы названия периода в винительном падеже для чисел 1, 2-4 и 5-20
+func onceInRU(n int, forms []string) string {
+	form := forms[2]
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		form = forms[0]
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		form = forms[1]
+	}
+	return "раз в " + strconv.Itoa(n) + " " + form
+}
+
+// monthsEN возвращает список месяцев из дополнительного параметра правила с индексом i
+// с учётом интервала в месяцах на английском языке
+func (r RepeatRules) monthsEN(i int) string {
+	every := ""
+	if r.every > 1 {
+		every = strconv.Itoa(r.every) + " months"
+	}
+	if len(r.nums) <= i {
+		return "every " + cmp.Or(every, "month")
+	}
+	months := joinWords(mapNums(sortedUnique(r.nums[i]), func(m int) string { return monthsEN[m-1] }), "and")
+	if every != "" {
+		return months + ", every " + every
+	}
+	return months
+}
+
+// monthsRU возвращает список месяцев из дополнительного параметра правила с индексом i
+// с учётом интервала в месяцах на русском языке
+func (r RepeatRules) monthsRU(i int) string {
+	every := ""
+	if r.every > 1 {
+		every = onceInRU(r.every, []string{"месяц", "месяца", "месяцев"})
+	}
+	if len(r.nums) <= i {
+		return cmp.Or(every, "каждого месяца")
+	}
+	months := joinWords(mapNums(sortedUnique(r.nums[i]), func(m int) string { return monthsRU[m-1] }), "и")
+	if every != "" {
+		return months + ", " + every
+	}
+	return months
+}
+
+// ordinalEN возвращает порядковое числительное для номера недели n на английском языке
+func ordinalEN(n int) string {
+	switch {
+	case n > 0:
+		return ordinalsEN[n-1]
+	case n == -1:
+		return "last"
+	}
+	return ordinalsEN[-n-1] + " to last"
+}
+
+// ordinalRU возвращает порядковое числительное для номера недели n в винительном падеже рода gender
+func ordinalRU(n int, gender int) string {
+	switch {
+	case n > 0:
+		return ordinalsRU[n-1][gender]
+	case n == -1:
+		return []string{"последний", "последнюю", "последнее"}[gender]
+	case n == -2:
+		return []string{"предпоследний", "предпоследнюю", "предпоследнее"}[gender]
+	}
+	return ordinalsRU[-n-1][gender] + " с конца"
+}
+
+// weekdayNameEN возвращает название дня недели wd на английском языке
+func weekdayNameEN(wd int) string {
+	return weekdaysEN[wd-1]
+}
+
+// englishSuffix возвращает суффикс порядкового числительного для числа day на английском языке
+func englishSuffix(day int) string {
+	if day%100 >= 11 && day%100 <= 13 {
+		return "th"
+	}
+	switch day % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	}
+	return "th"
+}
+
+// joinWords объединяет слова через запятую, а два последних — союзом conj: "a, b and c"
+func joinWords(words []string, conj string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " " + conj + " " + words[len(words)-1]
+}
+
+// mapNums преобразует числа nums в строки функцией f
+func mapNums(nums []int, f func(int) string) []string {
+	strs := make([]string, len(nums))
+	for i, num := range nums {
+		strs[i] = f(num)
+	}
+	return strs
+}
+
+// sortedUnique возвращает отсортированную копию nums без повторяющихся значений
+func sortedUnique(nums []int) []int {
+	sorted := slices.Clone(nums)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+// sortedDays возвращает отсортированную копию номеров дней nums без повторов,
+// при этом отрицательные номера (отсчёт с конца) располагаются после положительных
+func sortedDays(nums []int) []int {
+	sorted := sortedUnique(nums)
+	slices.SortStableFunc(sorted, func(a, b int) int {
+		if (a < 0) != (b < 0) {
+			if a < 0 {
+				return 1
+			}
+			return -1
+		}
+		return a - b
+	})
+	return sorted
+}
@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// Exceptions - исключения из серии повторений задачи: пропущенные и перенесённые повторения.
+// Исключения задаются по дням: пропуск или перенос относится к повторению, запланированному на этот день.
+//
+// Строковое представление - список через запятую, в котором дата 20060102 означает пропуск повторения,
+// а пара "20060102>20060105" - перенос повторения на другую дату с сохранением времени суток
+type Exceptions struct {
+	skip map[string]bool   // дни пропущенных повторений в формате 20060102
+	move map[string]string // перенесённые повторения: исходный день -> новая дата
+}
+
+// ParseExceptions разбирает строковое представление исключений value и проверяет их корректность
+func ParseExceptions(value string) (Exceptions, error) {
+	e := Exceptions{skip: map[string]bool{}, move: map[string]string{}}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		var err error
+		if date, to, found := strings.Cut(item, ">"); found {
+			err = e.Move(date, to)
+		} else {
+			err = e.Skip(item)
+		}
+		if err != nil {
+			return Exceptions{}, err
+		}
+	}
+	return e, nil
+}
+
+// String возвращает строковое представление исключений, упорядоченное по дням
+func (e Exceptions) String() string {
+	var items []string
+	for date := range e.skip {
+		items = append(items, date)
+	}
+	for date, to := range e.move {
+		items = append(items, date+">"+to)
+	}
+	slices.Sort(items)
+	return strings.Join(items, ",")
+}
+
+// Skip добавляет пропуск повторения, запланированного на день date
+func (e *Exceptions) Skip(date string) error {
+	date = strings.TrimSpace(date)
+	if _, err := time.Parse(settings.DateFormat, date); err != nil {
+		return fmt.Errorf("invalid exception date %q", date)
+	}
+	if _, ok := e.move[date]; ok {
+		return fmt.Errorf("occurrence on %s is already moved", date)
+	}
+	e.init()
+	e.skip[date] = true
+	return nil
+}
+
+// Move добавляет перенос повторения, запланированного на день date, на день to
+func (e *Exceptions) Move(date string, to string) error {
+	date, to = strings.TrimSpace(date), strings.TrimSpace(to)
+	if _, err := time.Parse(settings.DateFormat, date); err != nil {
+		return fmt.Errorf("invalid exception date %q", date)
+	}
+	if _, err := time.Parse(settings.DateFormat, to); err != nil {
+		return fmt.Errorf("invalid date %q to move the occurrence to", to)
+	}
+	if e.skip[date] {
+		return fmt.Errorf("occurrence on %s is already skipped", date)
+	}
+	if to == date {
+		return errors.New("occurrence cannot be moved to the same date")
+	}
+	if origin := e.Origin(to); origin != to && origin != date {
+		return fmt.Errorf("occurrence on %s is already moved to %s", origin, to)
+	}
+	e.init()
+	e.move[date] = to
+	return nil
+}
+
+// Remove удаляет исключение для повторения, запланированного на день date.
+// Возвращает false, если исключения не было
+func (e *Exceptions) Remove(date string) bool {
+	date = strings.TrimSpace(date)
+	_, moved := e.move[date]
+	if !moved && !e.skip[date] {
+		return false
+	}
+	delete(e.skip, date)
+	delete(e.move, date)
+	return true
+}
+
+// Prune удаляет исключения, которые относятся к повторениям, запланированным и перенесённым на дни раньше before
+func (e *Exceptions) Prune(before string) {
+	before = dayOf(before)
+	for date := range e.skip {
+		if date < before {
+			delete(e.skip, date)
+		}
+	}
+	for date, to := range e.move {
+		if date < before && to < before {
+			delete(e.move, date)
+		}
+	}
+}
+
+// init создает словари исключений, если они ещё не созданы
+func (e *Exceptions) init() {
+	if e.skip == nil {
+		e.skip = map[string]bool{}
+	}
+	if e.move == nil {
+		e.move = map[string]string{}
+	}
+}
+
+// Skipped определяет, пропущено ли повторение с датой date
+func (e Exceptions) Skipped(date string) bool {
+	return e.skip[dayOf(date)]
+}
+
+// target возвращает дату, на которую перенесено повторение с датой date, либо саму дату date
+func (e Exceptions) target(date string) string {
+	day, clock := SplitDateTime(date)
+	if to, ok := e.move[day]; ok {
+		return JoinDateTime(to, clock)
+	}
+	return date
+}
+
+// Origin возвращает исходную дату повторения, перенесённого на дату date, либо саму дату date
+func (e Exceptions) Origin(date string) string {
+	day, clock := SplitDateTime(date)
+	for from, to := range e.move {
+		if to == day {
+			return JoinDateTime(from, clock)
+		}
+	}
+	return date
+}
+
+// lastMoved возвращает самый поздний исходный день перенесённых повторений, либо пустую строку
+func (e Exceptions) lastMoved() string {
+	last := ""
+	for date := range e.move {
+		last = max(last, date)
+	}
+	return last
+}
+
+// firstMovedTo возвращает самый ранний исходный день повторений, перенесённых на день from или позже,
+// либо пустую строку
+func (e Exceptions) firstMovedTo(from string) string {
+	first := ""
+	for date, to := range e.move {
+		if to >= from && (first == "" || date < first) {
+			first = date
+		}
+	}
+	return first
+}
+
+// dayOf возвращает день в формате 20060102 из даты в формате 20060102 или "20060102 15:04"
+func dayOf(date string) string {
+	day, _ := SplitDateTime(date)
+	return day
+}
@@ -0,0 +1,420 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// Префикс строки правила повторения в формате iCalendar
+const rrulePrefix = "RRULE:"
+
+// Формат даты-времени в параметре UNTIL правила RRULE
+const rruleDateTimeFormat = "20060102T150405Z"
+
+// Коды дней недели в правилах RRULE, индекс соответствует номеру дня недели в правиле "w" минус 1
+var rruleWeekdays = []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// IsRRULE определяет, задано ли правило повторения repeat в формате iCalendar RRULE
+func IsRRULE(repeat string) bool {
+	repeat = strings.ToUpper(strings.TrimSpace(repeat))
+	return strings.HasPrefix(repeat, rrulePrefix) || strings.HasPrefix(repeat, "FREQ=")
+}
+
+// ToRRULE преобразует правило повторения repeat и условия окончания повторений count и until
+// в строку правила iCalendar RRULE (RFC 5545) без префикса "RRULE:"
+func ToRRULE(repeat string, count int, until string) (string, error) {
+	rules, err := parseRepeat(repeat)
+	if err != nil {
+		return "", err
+	}
+	if rules.datePart == "" {
+		return "", errors.New("task repetition rule is not set")
+	}
+	if err = CheckRepeatEnd(count, until); err != nil {
+		return "", err
+	}
+	if rules.businessDays || rules.shift != 0 {
+		return "", errors.New("working day modifiers cannot be converted to RRULE")
+	}
+	if rules.leapDay != "" {
+		return "", errors.New("leap day modifiers cannot be converted to RRULE")
+	}
+
+	var parts []string
+	switch rules.datePart {
+	case "d":
+		parts = append(parts, "FREQ=DAILY")
+		if rules.nums[0][0] > 1 {
+			parts = append(parts, "INTERVAL="+strconv.Itoa(rules.nums[0][0]))
+		}
+	case "h", "min":
+		parts = append(parts, map[string]string{"h": "FREQ=HOURLY", "min": "FREQ=MINUTELY"}[rules.datePart])
+		if rules.nums[0][0] > 1 {
+			parts = append(parts, "INTERVAL="+strconv.Itoa(rules.nums[0][0]))
+		}
+	case "y":
+		parts = append(parts, "FREQ=YEARLY")
+		if len(rules.yearDates) > 0 {
+			days, months, err := rruleYearDates(rules.yearDates)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, "BYMONTH="+joinInts(months), "BYMONTHDAY="+joinInts(days))
+		}
+	case "w":
+		parts = append(parts, "FREQ=WEEKLY", "BYDAY="+rruleByDay(nil, rules.nums[0]))
+	case "m":
+		parts = append(parts, "FREQ=MONTHLY", "BYMONTHDAY="+joinInts(rules.nums[0]))
+		if len(rules.nums) == 2 {
+			parts = append(parts, "BYMONTH="+joinInts(rules.nums[1]))
+		}
+	case "n":
+		parts = append(parts, "FREQ=MONTHLY", "BYDAY="+rruleByDay(rules.nums[0], rules.nums[1]))
+		if len(rules.nums) == 3 {
+			parts = append(parts, "BYMONTH="+joinInts(rules.nums[2]))
+		}
+	default:
+		return "", fmt.Errorf("date part '%s' cannot be converted to RRULE", rules.datePart)
+	}
+
+	if rules.every > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rules.every))
+	}
+	if count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(count))
+	}
+	if until = strings.TrimSpace(until); until != "" {
+		parts = append(parts, "UNTIL="+until)
+	}
+
+	return strings.Join(parts, ";"), nil
+}
+
+// FromRRULE преобразует строку правила iCalendar RRULE (RFC 5545) в правило повторения задачи repeat
+// и условия окончания повторений: count — количество повторений, until — дата окончания в формате 20060102.
+// Поддерживаются параметры FREQ (включая HOURLY и MINUTELY), INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, COUNT, UNTIL и WKST.
+// Не заданные для FREQ=MONTHLY и FREQ=YEARLY день месяца и месяц берутся, как DTSTART в RFC 5545, из даты начала start
+func FromRRULE(rrule string, start time.Time) (repeat string, count int, until string, err error) {
+	rrule = strings.TrimSpace(rrule)
+	if strings.HasPrefix(strings.ToUpper(rrule), rrulePrefix) {
+		rrule = rrule[len(rrulePrefix):]
+	}
+
+	// разбираем правило на пары ПАРАМЕТР=ЗНАЧЕНИЕ
+	params := map[string]string{}
+	for _, part := range strings.Split(rrule, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return "", 0, "", fmt.Errorf("invalid RRULE part %q", part)
+		}
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if _, ok := params[name]; ok {
+			return "", 0, "", fmt.Errorf("RRULE parameter %s is specified more than once", name)
+		}
+		params[name] = strings.ToUpper(strings.TrimSpace(value))
+	}
+
+	interval := 1
+	if value, ok := params["INTERVAL"]; ok {
+		interval, err = strconv.Atoi(value)
+		if err != nil || interval < 1 {
+			return "", 0, "", errors.New("RRULE INTERVAL must be a positive number")
+		}
+	}
+	if value, ok := params["COUNT"]; ok {
+		count, err = strconv.Atoi(value)
+		if err != nil || count < 1 {
+			return "", 0, "", errors.New("RRULE COUNT must be a positive number")
+		}
+	}
+	if value, ok := params["UNTIL"]; ok {
+		until, err = rruleUntil(value)
+		if err != nil {
+			return "", 0, "", err
+		}
+	}
+	if count > 0 && until != "" {
+		return "", 0, "", errors.New("RRULE COUNT and UNTIL cannot be specified together")
+	}
+
+	for name := range params {
+		switch name {
+		case "FREQ", "INTERVAL", "BYDAY", "BYMONTHDAY", "BYMONTH", "COUNT", "UNTIL", "WKST":
+		default:
+			return "", 0, "", fmt.Errorf("RRULE parameter %s is not supported", name)
+		}
+	}
+
+	byDay, hasByDay := params["BYDAY"]
+	byMonthDay, hasByMonthDay := params["BYMONTHDAY"]
+	byMonth, hasByMonth := params["BYMONTH"]
+	if hasByDay && hasByMonthDay {
+		return "", 0, "", errors.New("RRULE BYDAY and BYMONTHDAY cannot be combined")
+	}
+
+	var months []int
+	if hasByMonth {
+		months, err = parseInts(byMonth, 1, 12)
+		if err != nil {
+			return "", 0, "", fmt.Errorf("RRULE BYMONTH: %w", err)
+		}
+	}
+
+	switch freq := params["FREQ"]; freq {
+	case "DAILY":
+		if hasByDay || hasByMonthDay || hasByMonth {
+			return "", 0, "", errors.New("RRULE with FREQ=DAILY does not support BYDAY, BYMONTHDAY and BYMONTH")
+		}
+		if interval > 400 {
+			return "", 0, "", errors.New("RRULE INTERVAL for FREQ=DAILY cannot be greater than 400")
+		}
+		repeat = "d " + strconv.Itoa(interval)
+
+	case "HOURLY", "MINUTELY":
+		if hasByDay || hasByMonthDay || hasByMonth {
+			return "", 0, "", fmt.Errorf("RRULE with FREQ=%s does not support BYDAY, BYMONTHDAY and BYMONTH", freq)
+		}
+		part, limit := "h", 168
+		if freq == "MINUTELY" {
+			part, limit = "min", 1440
+		}
+		if interval > limit {
+			return "", 0, "", fmt.Errorf("RRULE INTERVAL for FREQ=%s cannot be greater than %d", freq, limit)
+		}
+		repeat = part + " " + strconv.Itoa(interval)
+
+	case "WEEKLY":
+		if hasByMonthDay || hasByMonth {
+			return "", 0, "", errors.New("RRULE with FREQ=WEEKLY does not support BYMONTHDAY and BYMONTH")
+		}
+		if !hasByDay { // без указания дней недели задача повторяется с интервалом в неделях от даты начала
+			if interval*7 > 400 {
+				return "", 0, "", errors.New("RRULE INTERVAL for FREQ=WEEKLY cannot be greater than 57")
+			}
+			repeat = "d " + strconv.Itoa(interval*7)
+			break
+		}
+		if interval > maxEvery {
+			return "", 0, "", fmt.Errorf("RRULE INTERVAL for FREQ=WEEKLY with BYDAY cannot be greater than %d", maxEvery)
+		}
+		ordinals, weekdays, err := parseByDay(byDay)
+		if err != nil {
+			return "", 0, "", err
+		}
+		if len(ordinals) > 0 {
+			return "", 0, "", errors.New("RRULE BYDAY with ordinal numbers requires FREQ=MONTHLY or FREQ=YEARLY")
+		}
+		repeat = "w " + joinInts(weekdays) + rruleEvery(interval)
+
+	case "MONTHLY", "YEARLY":
+		// интервал в годах поддерживается для повторения в годовщину даты начала и в заданные даты года
+		if interval > maxEvery || (freq == "YEARLY" && interval != 1 && hasByDay) {
+			return "", 0, "", fmt.Errorf("RRULE INTERVAL for FREQ=%s is not supported", freq)
+		}
+		if freq == "YEARLY" && hasByDay && !hasByMonth {
+			return "", 0, "", errors.New("RRULE with FREQ=YEARLY and BYDAY requires BYMONTH")
+		}
+		switch {
+		case hasByDay:
+			ordinals, weekdays, err := parseByDay(byDay)
+			if err != nil {
+				return "", 0, "", err
+			}
+			if len(ordinals) == 0 {
+				return "", 0, "", fmt.Errorf("RRULE BYDAY for FREQ=%s requires ordinal numbers, e.g. 2TU", freq)
+			}
+			repeat = "n " + joinInts(ordinals) + " " + joinInts(weekdays)
+		case freq == "YEARLY" && !hasByMonth && !hasByMonthDay:
+			repeat = "y" // годовщина даты начала
+		default:
+			// без BYMONTHDAY повторение приходится на день месяца даты начала, а без BYMONTH - на её месяц
+			days := []int{start.Day()}
+			if hasByMonthDay {
+				days, err = parseInts(byMonthDay, -2, 31)
+				if err != nil || slices.Contains(days, 0) {
+					return "", 0, "", errors.New("RRULE BYMONTHDAY values must be between 1 and 31 or -1, -2")
+				}
+			}
+			if freq == "YEARLY" {
+				if !hasByMonth {
+					months = []int{int(start.Month())}
+				}
+				if repeat, err = rruleYearRepeat(days, months); err != nil {
+					return "", 0, "", err
+				}
+				return repeat + rruleEvery(interval), count, until, nil
+			}
+			repeat = "m " + joinInts(days)
+		}
+		if len(months) > 0 {
+			repeat += " " + joinInts(months)
+		}
+		repeat += rruleEvery(interval)
+
+	case "":
+		return "", 0, "", errors.New("RRULE FREQ is not specified")
+	default:
+		return "", 0, "", fmt.Errorf("RRULE FREQ=%s is not supported", freq)
+	}
+
+	return repeat, count, until, nil
+}
+
+// rruleByDay формирует значение параметра BYDAY из номеров недель ordinals и дней недели weekdays.
+// Каждый номер недели комбинируется с каждым днем недели
+func rruleByDay(ordinals []int, weekdays []int) string {
+	var days []string
+	for _, wd := range weekdays {
+		if len(ordinals) == 0 {
+			days = append(days, rruleWeekdays[wd-1])
+			continue
+		}
+		for _, n := range ordinals {
+			days = append(days, strconv.Itoa(n)+rruleWeekdays[wd-1])
+		}
+	}
+	return strings.Join(days, ",")
+}
+
+// parseByDay разбирает значение параметра BYDAY на номера недель и дни недели.
+// Список дней должен представлять собой все сочетания номеров недель и дней недели,
+// так как в правиле "n" каждый номер недели комбинируется с каждым днем недели
+func parseByDay(value string) (ordinals []int, weekdays []int, err error) {
+	pairs := map[[2]int]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) < 2 {
+			return nil, nil, fmt.Errorf("invalid RRULE BYDAY value %q", item)
+		}
+		wd := slices.Index(rruleWeekdays, item[len(item)-2:]) + 1
+		if wd == 0 {
+			return nil, nil, fmt.Errorf("invalid RRULE BYDAY weekday %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n < -5 || n > 5 || n == 0 {
+				return nil, nil, fmt.Errorf("RRULE BYDAY ordinal in %q must be between 1 and 5 or -1 and -5", item)
+			}
+		}
+		pairs[[2]int{n, wd}] = true
+		if !slices.Contains(ordinals, n) {
+			ordinals = append(ordinals, n)
+		}
+		if !slices.Contains(weekdays, wd) {
+			weekdays = append(weekdays, wd)
+		}
+	}
+
+	if slices.Contains(ordinals, 0) {
+		if len(ordinals) > 1 {
+			return nil, nil, errors.New("RRULE BYDAY cannot mix weekdays with and without ordinal numbers")
+		}
+		return nil, weekdays, nil
+	}
+	if len(pairs) != len(ordinals)*len(weekdays) {
+		return nil, nil, errors.New("RRULE BYDAY must contain every combination of the listed ordinals and weekdays")
+	}
+	return ordinals, weekdays, nil
+}
+
+// rruleEvery возвращает модификатор интервала в периодах правила для значения INTERVAL,
+// либо пустую строку для интервала 1
+func rruleEvery(interval int) string {
+	if interval <= 1 {
+		return ""
+	}
+	return " " + ModEvery + strconv.Itoa(interval)
+}
+
+// rruleUntil преобразует значение параметра UNTIL (дата или дата-время) в дату формата 20060102
+func rruleUntil(value string) (string, error) {
+	if date, err := time.Parse(settings.DateFormat, value); err == nil {
+		return date.Format(settings.DateFormat), nil
+	}
+	if date, err := time.Parse(rruleDateTimeFormat, value); err == nil {
+		return date.Format(settings.DateFormat), nil
+	}
+	if date, err := time.Parse(strings.TrimSuffix(rruleDateTimeFormat, "Z"), value); err == nil {
+		return date.Format(settings.DateFormat), nil
+	}
+	return "", errors.New("invalid RRULE UNTIL date format")
+}
+
+// parseInts разбирает список чисел через запятую и проверяет, что они лежат в диапазоне от low до high
+func parseInts(value string, low int, high int) ([]int, error) {
+	var nums []int
+	for _, e := range strings.Split(value, ",") {
+		num, err := strconv.Atoi(strings.TrimSpace(e))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", e)
+		}
+		if num < low || num > high {
+			return nil, fmt.Errorf("value %d must be between %d and %d", num, low, high)
+		}
+		nums = append(nums, num)
+	}
+	return nums, nil
+}
+
+// joinInts объединяет числа в строку через запятую
+func joinInts(nums []int) string {
+	strs := make([]string, len(nums))
+	for i, num := range nums {
+		strs[i] = strconv.Itoa(num)
+	}
+	return strings.Join(strs, ",")
+}
+
+// rruleYearRepeat возвращает правило "y" с датами из всех сочетаний дней days и месяцев months значений
+// BYMONTHDAY и BYMONTH. Несуществующие даты, например 31.04, пропускаются, как это предусмотрено RFC 5545
+func rruleYearRepeat(days []int, months []int) (string, error) {
+	var dates []string
+	for _, month := range months {
+		for _, day := range days {
+			if day > LastDayOfMonth(time.Date(2024, time.Month(month), 1, 0, 0, 0, 0, time.UTC)) {
+				continue
+			}
+			if day < 0 {
+				dates = append(dates, fmt.Sprintf("%d.%02d", day, month))
+				continue
+			}
+			dates = append(dates, fmt.Sprintf("%02d.%02d", day, month))
+		}
+	}
+	if len(dates) == 0 {
+		return "", errors.New("RRULE BYMONTH and BYMONTHDAY do not form any date")
+	}
+	return "y " + strings.Join(dates, ","), nil
+}
+
+// rruleYearDates раскладывает даты правила "y" на списки дней и месяцев для BYMONTHDAY и BYMONTH.
+// Возвращает ошибку, если даты не образуют все сочетания этих дней и месяцев
+func rruleYearDates(dates [][2]int) ([]int, []int, error) {
+	var days, months []int
+	for _, date := range dates {
+		if !slices.Contains(days, date[0]) {
+			days = append(days, date[0])
+		}
+		if !slices.Contains(months, date[1]) {
+			months = append(months, date[1])
+		}
+	}
+	for _, day := range days {
+		for _, month := range months {
+			if !slices.Contains(dates, [2]int{day, month}) {
+				return nil, nil, errors.New("yearly dates cannot be converted to RRULE BYMONTH and BYMONTHDAY")
+			}
+		}
+	}
+	return days, months, nil
+}
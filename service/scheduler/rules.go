@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule - вид правила повторения, обозначаемый первым словом правила: частью даты (d, w, m, n, y)
+// или частью времени (h, min). Новые виды правил, в том числе вне пакета, добавляются регистрацией реализации
+// функцией Register. Параметры и модификаторы разобранного правила доступны реализациям через методы RepeatRules
+// Args, Every, BusinessDays, Shift, YearDates и LeapDay
+type Rule interface {
+	// Info возвращает сведения о виде правила: обозначение, синтаксис и допустимые модификаторы
+	Info() RuleInfo
+	// Parse разбирает дополнительный параметр правила value и добавляет его в правило rules
+	Parse(rules *RepeatRules, value string) error
+	// Validate проверяет количество и диапазоны дополнительных параметров правила rules,
+	// полученного из слов (токенов) tokens. Возвращает *RuleError с указанием ошибочного токена
+	Validate(rules RepeatRules, tokens []string) error
+	// Next возвращает ближайшую дату повторения по правилу rules строго после даты after
+	// без учета переноса дат с нерабочих дней. begDate - дата начала отсчёта повторений
+	Next(rules RepeatRules, begDate time.Time, after time.Time) (time.Time, error)
+	// Describe возвращает описание правила rules на языке lang (LangRU или LangEN) без учета переноса дат
+	Describe(rules RepeatRules, lang string) string
+}
+
+// PeriodRule - вид правила, повторения которого группируются в периоды (недели, месяцы).
+// Для таких правил интервал модификатора /N отсчитывается в периодах от даты начала,
+// остальные правила, допускающие модификатор /N, учитывают интервал в методе Next самостоятельно
+type PeriodRule interface {
+	Rule
+	// Period возвращает номер периода даты date относительно периода даты начала begDate
+	Period(begDate time.Time, date time.Time) int
+}
+
+// RuleInfo - сведения о виде правила повторения
+type RuleInfo struct {
+	Name        string   `json:"name"`                  // обозначение вида правила - первое слово правила
+	Syntax      string   `json:"syntax"`                // синтаксис правила
+	Example     string   `json:"example"`               // пример правила
+	Description string   `json:"description,omitempty"` // описание примера правила, заполняется функцией Rules
+	Modifiers   []string `json:"modifiers,omitempty"`   // допустимые модификаторы, включая префикс ModEvery
+	TimeBased   bool     `json:"time_based,omitempty"`  // правило задаёт повторение по часам или минутам
+}
+
+// registry - зарегистрированные виды правил повторения по их обозначениям
+var registry = map[string]Rule{}
+
+// ruleNames - обозначения зарегистрированных видов правил повторения в порядке регистрации
+var ruleNames []string
+
+func init() {
+	Register(dayRule{})
+	Register(monthRule{})
+	Register(yearRule{})
+	Register(weekRule{})
+	Register(weekdayRule{})
+	Register(timeRule{name: "h", unit: time.Hour, maxInterval: 168})
+	Register(timeRule{name: "min", unit: time.Minute, maxInterval: 1440})
+}
+
+// Register регистрирует вид правила повторения rule. Обозначение вида правила должно быть уникальным
+// и не совпадать с модификаторами. Должна вызываться при запуске приложения до обработки запросов
+func Register(rule Rule) {
+	name := rule.Info().Name
+	if name == "" || strings.ContainsAny(name, " \t\n") || strings.HasPrefix(name, ModEvery) ||
+		slices.Contains(PossibleMods, name) {
+		panic("scheduler: invalid rule name " + strconv.Quote(name))
+	}
+	if _, ok := registry[name]; ok {
+		panic("scheduler: rule " + strconv.Quote(name) + " is already registered")
+	}
+	registry[name] = rule
+	ruleNames = append(ruleNames, name)
+}
+
+// PossibleVals возвращает обозначения зарегистрированных видов правил повторения в порядке регистрации
+func PossibleVals() []string {
+	return slices.Clone(ruleNames)
+}
+
+// Rules возвращает сведения о зарегистрированных видах правил повторения в порядке регистрации
+// с описанием примеров правил на языке lang
+func Rules(lang string) []RuleInfo {
+	infos := make([]RuleInfo, 0, len(ruleNames))
+	for _, name := range ruleNames {
+		info := registry[name].Info()
+		info.Description, _ = Describe(info.Example, lang)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Args возвращает дополнительные параметры правила: по списку чисел на каждый параметр
+func (r RepeatRules) Args() [][]int {
+	return r.nums
+}
+
+// AddArg добавляет в правило дополнительный параметр - список чисел nums
+func (r *RepeatRules) AddArg(nums []int) {
+	r.nums = append(r.nums, nums)
+}
+
+// Every возвращает интервал в периодах правила, заданный модификатором /N; 0 - каждый период
+func (r RepeatRules) Every() int {
+	return r.every
+}
+
+// BusinessDays определяет, задан ли в правиле модификатор ModBusinessDays: интервал считается в рабочих днях
+func (r RepeatRules) BusinessDays() bool {
+	return r.businessDays
+}
+
+// Shift возвращает перенос даты, выпавшей на нерабочий день: 1 - на следующий рабочий день (ModForward),
+// -1 - на предыдущий (ModBackward), 0 - без переноса. Перенос выполняется после метода Rule.Next
+func (r RepeatRules) Shift() int {
+	return r.shift
+}
+
+// YearDates возвращает даты правила "y" в виде пар {день, месяц}; пустой список - годовщина даты начала
+func (r RepeatRules) YearDates() [][2]int {
+	return slices.Clone(r.yearDates)
+}
+
+// LeapDay возвращает замену 29 февраля в невисокосные годы: ModFeb28, ModMar1 или "" - по умолчанию (ModMar1)
+func (r RepeatRules) LeapDay() string {
+	return r.leapDay
+}
+
+// ParseNums разбирает дополнительный параметр правила value - список целых чисел через запятую
+func ParseNums(value string) ([]int, error) {
+	var nums []int
+	for _, e := range strings.Split(value, ",") {
+		num, err := strconv.Atoi(e)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a number", e)
+		}
+		nums = append(nums, num)
+	}
+	return nums, nil
+}
+
+// numArgs - разбор дополнительных параметров правила, заданных списками чисел через запятую
+type numArgs struct{}
+
+// Parse добавляет в правило rules дополнительный параметр value - список чисел через запятую
+func (numArgs) Parse(rules *RepeatRules, value string) error {
+	nums, err := ParseNums(value)
+	if err != nil {
+		return err
+	}
+	rules.AddArg(nums)
+	return nil
+}
+
+// dayRule - правило "d <интервал>": повторение через заданное количество дней
+type dayRule struct{ numArgs }
+
+func (dayRule) Info() RuleInfo {
+	return RuleInfo{
+		Name:      "d",
+		Syntax:    "d <1-400> [bd|fwd|bwd]",
+		Example:   "d 7",
+		Modifiers: []string{ModBusinessDays, ModForward, ModBackward},
+	}
+}
+
+// weekRule - правило "w <дни недели>": повторение в заданные дни недели
+type weekRule struct{ numArgs }
+
+func (weekRule) Info() RuleInfo {
+	return RuleInfo{
+		Name:      "w",
+		Syntax:    "w <1-7,...> [fwd|bwd] [/N]",
+		Example:   "w 1,4",
+		Modifiers: []string{ModForward, ModBackward, ModEvery},
+	}
+}
+
+// Period возвращает количество недель между неделями дат begDate и date. Недели начинаются с понедельника
+func (weekRule) Period(begDate time.Time, date time.Time) int {
+	return daysBetween(weekStart(begDate), weekStart(date)) / 7
+}
+
+// monthRule - правило "m <дни месяца> [месяцы]": повторение в заданные дни месяца
+type monthRule struct{ numArgs }
+
+func (monthRule) Info() RuleInfo {
+	return RuleInfo{
+		Name:      "m",
+		Syntax:    "m <1-31,-1,-2,...> [1-12,...] [fwd|bwd] [/N]",
+		Example:   "m 1,-1",
+		Modifiers: []string{ModForward, ModBackward, ModEvery},
+	}
+}
+
+// Period возвращает количество месяцев между месяцами дат begDate и date
+func (monthRule) Period(begDate time.Time, date time.Time) int {
+	return monthsBetween(begDate, date)
+}
+
+// weekdayRule - правило "n <номера недель> <дни недели> [месяцы]": повторение в n-й день недели месяца
+type weekdayRule struct{ numArgs }
+
+func (weekdayRule) Info() RuleInfo {
+	return RuleInfo{
+		Name:      "n",
+		Syntax:    "n <1-5,-1,...-5,...> <1-7,...> [1-12,...] [fwd|bwd] [/N]",
+		Example:   "n 2 2",
+		Modifiers: []string{ModForward, ModBackward, ModEvery},
+	}
+}
+
+// Period возвращает количество месяцев между месяцами дат begDate и date
+func (weekdayRule) Period(begDate time.Time, date time.Time) int {
+	return monthsBetween(begDate, date)
+}
+
+// yearRule - правило "y [ДД.ММ,...]": ежегодное повторение в годовщину даты начала либо в заданные даты
+type yearRule struct{}
+
+func (yearRule) Info() RuleInfo {
+	return RuleInfo{
+		Name:      "y",
+		Syntax:    "y [DD.MM,...] [feb28|mar1] [fwd|bwd] [/N]",
+		Example:   "y 05.03",
+		Modifiers: []string{ModForward, ModBackward, ModFeb28, ModMar1, ModEvery},
+	}
+}
+
+// Parse устанавливает в правиле rules список дат value в формате ДД.ММ через запятую
+func (yearRule) Parse(rules *RepeatRules, value string) error {
+	return rules.setYearDates(value)
+}
+
+// timeRule - правила "h <интервал>" и "min <интервал>": повторение через заданное количество часов или минут
+type timeRule struct {
+	numArgs
+	name        string        // обозначение вида правила
+	unit        time.Duration // единица интервала
+	maxInterval int           // максимальный интервал в единицах
+}
+
+func (r timeRule) Info() RuleInfo {
+	return RuleInfo{
+		Name:      r.name,
+		Syntax:    r.name + " <1-" + strconv.Itoa(r.maxInterval) + ">",
+		Example:   r.name + " 4",
+		TimeBased: true,
+	}
+}
+
+// monthsBetween возвращает количество месяцев между месяцами дат from и to
+func monthsBetween(from time.Time, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
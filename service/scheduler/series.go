@@ -0,0 +1,357 @@
+package scheduler
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// maxIterations - ограничение на количество перебираемых повторений задачи при поиске дат в интервале
+const maxIterations = 100000
+
+// ErrTooManyOccurrences - количество повторений задачи в интервале превышает ограничение перебора
+var ErrTooManyOccurrences = errors.New("too many task occurrences in the interval, narrow the interval")
+
+// Режимы отсчёта повторений задачи
+const (
+	RepeatFromSchedule   = "schedule"   // от запланированной даты задачи
+	RepeatFromCompletion = "completion" // от фактической даты выполнения задачи
+)
+
+// Политики обработки просроченных повторений задачи при её выполнении
+const (
+	OverdueSkip   = "skip"   // переход к ближайшему будущему повторению, пропущенные повторения отбрасываются
+	OverdueStep   = "step"   // переход к следующему повторению, даже если оно уже прошло
+	OverdueRecord = "record" // переход к ближайшему будущему повторению с записью пропущенных повторений в историю
+)
+
+// Series - серия повторений задачи
+type Series struct {
+	Date   string // дата текущего повторения задачи в формате 20060102 или "20060102 15:04"
+	Repeat string // правило повторения
+	Count  int    // оставшееся количество повторений, включая текущее; 0 — без ограничений
+	Until  string // дата в формате 20060102, после которой задача не повторяется; "" — без ограничений
+	From   string // режим отсчёта повторений: RepeatFromSchedule (по умолчанию) или RepeatFromCompletion
+	// политика обработки просроченных повторений: OverdueSkip (по умолчанию), OverdueStep или OverdueRecord;
+	// действует только в режиме RepeatFromSchedule
+	Overdue string
+
+	Exceptions string // исключения из серии: пропущенные и перенесённые повторения, см. ParseExceptions
+
+	Location *time.Location // часовой пояс, в котором разбираются даты серии; nil - часовой пояс по умолчанию
+}
+
+// CheckRepeatFrom проверяет режим отсчёта повторений from и возвращает его с подстановкой значения по умолчанию
+func CheckRepeatFrom(from string) (string, error) {
+	switch from = strings.TrimSpace(from); from {
+	case "":
+		return RepeatFromSchedule, nil
+	case RepeatFromSchedule, RepeatFromCompletion:
+		return from, nil
+	}
+	return "", errors.New("invalid repeat mode, must be 'schedule' or 'completion'")
+}
+
+// CheckOverdue проверяет политику обработки просроченных повторений overdue
+// и возвращает её с подстановкой значения по умолчанию
+func CheckOverdue(overdue string) (string, error) {
+	switch overdue = strings.TrimSpace(overdue); overdue {
+	case "":
+		return OverdueSkip, nil
+	case OverdueSkip, OverdueStep, OverdueRecord:
+		return overdue, nil
+	}
+	return "", errors.New("invalid overdue policy, must be 'skip', 'step' or 'record'")
+}
+
+// NextDate возвращает дату следующего повторения серии с учетом режима отсчёта и условий окончания повторений.
+// Если повторения исчерпаны, возвращает пустую строку.
+//
+//	now — для режима RepeatFromSchedule время, после которого ищется ближайшая дата (аналогично функции NextDate);
+//	для режима RepeatFromCompletion — дата выполнения задачи, от которой отсчитывается следующее повторение
+func (s Series) NextDate(now string) (string, error) {
+	if err := CheckRepeatEnd(s.Count, s.Until); err != nil {
+		return "", err
+	}
+	mode, err := CheckRepeatFrom(s.From)
+	if err != nil {
+		return "", err
+	}
+	overdue, err := CheckOverdue(s.Overdue)
+	if err != nil {
+		return "", err
+	}
+	if s.Count == 1 { // текущее повторение последнее
+		return "", nil
+	}
+
+	exceptions, err := ParseExceptions(s.Exceptions)
+	if err != nil {
+		return "", err
+	}
+	// если текущее повторение перенесено, повторения отсчитываются от его исходной даты
+	date := exceptions.Origin(s.Date)
+
+	nextDate := ""
+	switch {
+	case mode == RepeatFromCompletion:
+		nextDate, err = nextDateFromDone(now, date, s.Repeat, s.Location)
+	case overdue == OverdueStep: // повторения перебираются по одному, независимо от текущей даты
+		nextDate, err = nextOccurrence(date, s.Repeat, s.Location)
+	default:
+		nextDate, err = NextDateIn(now, date, s.Repeat, s.Location)
+	}
+	if err != nil || nextDate == "" {
+		return "", err
+	}
+
+	// пропущенные повторения не учитываются в количестве повторений
+	if exceptions.Skipped(nextDate) {
+		rules, err := parseRepeat(s.Repeat)
+		if err != nil {
+			return "", err
+		}
+		begDate, _, err := parseDateTime(date, s.Location)
+		if err != nil {
+			return "", err
+		}
+		curDate, withTime, err := parseDateTime(nextDate, s.Location)
+		if err != nil {
+			return "", err
+		}
+		for i := 0; exceptions.Skipped(nextDate); i++ {
+			if i >= maxIterations || s.afterUntil(nextDate) {
+				return "", nil
+			}
+			if mode == RepeatFromCompletion {
+				begDate = curDate
+			}
+			if curDate, err = nextAfter(rules, begDate, curDate); err != nil {
+				return "", err
+			}
+			nextDate = formatDate(curDate, withTime)
+		}
+	}
+	if s.afterUntil(nextDate) {
+		return "", nil
+	}
+	return exceptions.target(nextDate), nil
+}
+
+// afterUntil определяет, наступает ли дата date после даты окончания повторений серии
+func (s Series) afterUntil(date string) bool {
+	until := strings.TrimSpace(s.Until)
+	return until != "" && dayOf(date) > until
+}
+
+// Missed возвращает даты повторений серии между текущим повторением и повторением next, не включая их,
+// в формате 20060102 или, для задач со временем, "20060102 15:04". Если next пустая строка (повторения исчерпаны),
+// возвращаются повторения до даты окончания повторений включительно.
+// Пропущенные по исключениям повторения не возвращаются, перенесённые возвращаются с новой датой
+func (s Series) Missed(next string) ([]string, error) {
+	exceptions, err := ParseExceptions(s.Exceptions)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseRepeat(s.Repeat)
+	if err != nil || rules.datePart == "" {
+		return nil, err
+	}
+	begDate, withTime, err := parseDateTime(exceptions.Origin(s.Date), s.Location)
+	if err != nil {
+		return nil, err
+	}
+	var endDate time.Time
+	switch until := strings.TrimSpace(s.Until); {
+	case next != "":
+		if endDate, _, err = parseDateTime(exceptions.Origin(next), s.Location); err != nil {
+			return nil, err
+		}
+	case until != "":
+		if endDate, err = time.ParseInLocation(settings.DateFormat, until, locationOrDefault(s.Location)); err != nil {
+			return nil, err
+		}
+		endDate = endDate.AddDate(0, 0, 1)
+	default:
+		return nil, nil
+	}
+
+	withTime = withTime || rules.timeBased()
+	dates := []string{}
+	curDate := begDate
+	// количество повторений включает текущее повторение
+	for i := 0; i < maxIterations && (s.Count == 0 || len(dates) < s.Count-1); i++ {
+		if curDate, err = nextAfter(rules, begDate, curDate); err != nil {
+			return nil, err
+		}
+		if !curDate.Before(endDate) {
+			break
+		}
+		if date := formatDate(curDate, withTime); !exceptions.Skipped(date) {
+			dates = append(dates, exceptions.target(date))
+		}
+	}
+	return dates, nil
+}
+
+// nextOccurrence возвращает дату повторения по правилу repeat, следующего непосредственно за датой date
+func nextOccurrence(date string, repeat string, loc *time.Location) (string, error) {
+	begDate, withTime, err := parseDateTime(date, loc)
+	if err != nil {
+		return "", err
+	}
+	rules, err := parseRepeat(repeat)
+	if err != nil || rules.datePart == "" {
+		return "", err
+	}
+	nextDate, err := nextAfter(rules, begDate, begDate)
+	if err != nil {
+		return "", err
+	}
+	return formatDate(nextDate, withTime || rules.timeBased()), nil
+}
+
+// nextDateFromDone возвращает ближайшую дату повторения по правилу repeat, отсчитывая её от даты выполнения done.
+// Правила по датам сохраняют время суток исходной даты задачи date, правила по часам и минутам
+// отсчитываются от точного времени выполнения
+func nextDateFromDone(done string, date string, repeat string, loc *time.Location) (string, error) {
+	doneDate, _, err := parseDateTime(done, loc)
+	if err != nil {
+		return "", err
+	}
+	begDate, withTime, err := parseDateTime(date, loc)
+	if err != nil {
+		return "", err
+	}
+	rules, err := parseRepeat(repeat)
+	if err != nil {
+		return "", err
+	}
+	if rules.datePart == "" {
+		return "", nil
+	}
+
+	if !rules.timeBased() {
+		doneDate = withClock(doneDate, begDate)
+	}
+	nextDate, err := nextAfter(rules, doneDate, doneDate)
+	if err != nil {
+		return "", err
+	}
+	return formatDate(nextDate, withTime || rules.timeBased()), nil
+}
+
+// Occurrences возвращает все даты повторений серии s в интервале дней от from до to включительно
+// в формате 20060102 или, для задач со временем, "20060102 15:04".
+// Первым повторением серии считается дата текущего повторения задачи s.Date. Для режима RepeatFromCompletion
+// предполагается, что каждое повторение выполняется в запланированную дату.
+// Пропущенные повторения не возвращаются, перенесённые возвращаются с новой датой.
+// Если повторений в интервале больше ограничения перебора, возвращает ErrTooManyOccurrences
+func (s Series) Occurrences(from string, to string) ([]string, error) {
+	exceptions, err := ParseExceptions(s.Exceptions)
+	if err != nil {
+		return nil, err
+	}
+	loc := locationOrDefault(s.Location)
+	begDate, withTime, err := parseDateTime(exceptions.Origin(s.Date), loc)
+	if err != nil {
+		return nil, err
+	}
+	fromDate, err := time.ParseInLocation(settings.DateFormat, strings.TrimSpace(from), loc)
+	if err != nil {
+		return nil, err
+	}
+	toDate, err := time.ParseInLocation(settings.DateFormat, strings.TrimSpace(to), loc)
+	if err != nil {
+		return nil, err
+	}
+	if toDate.Before(fromDate) {
+		return nil, errors.New("end of the interval is earlier than its beginning")
+	}
+	toDate = toDate.AddDate(0, 0, 1) // день to входит в интервал целиком
+
+	rules, err := parseRepeat(s.Repeat)
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckRepeatEnd(s.Count, s.Until); err != nil {
+		return nil, err
+	}
+	mode, err := CheckRepeatFrom(s.From)
+	if err != nil {
+		return nil, err
+	}
+	// повторения перебираются до конца интервала либо до последнего перенесённого повторения,
+	// если оно перенесено в интервал с более поздней даты; дата окончания повторений сужает интервал перебора
+	endDate := toDate
+	if last := exceptions.lastMoved(); last != "" {
+		lastDate, _ := time.ParseInLocation(settings.DateFormat, last, loc)
+		if lastDate = lastDate.AddDate(0, 0, 1); lastDate.After(endDate) {
+			endDate = lastDate
+		}
+	}
+	if until := strings.TrimSpace(s.Until); until != "" {
+		untilDate, _ := time.ParseInLocation(settings.DateFormat, until, loc)
+		if untilDate = untilDate.AddDate(0, 0, 1); untilDate.Before(endDate) {
+			endDate = untilDate
+		}
+	}
+
+	withTime = withTime || rules.timeBased()
+	dates := []string{}
+	count := 0 // количество повторений серии без учёта пропущенных
+	curDate := begDate
+	// без ограничения количества повторений перебор начинается с начала интервала либо с исходного дня
+	// повторения, перенесённого в интервал с более ранней даты; в режиме RepeatFromCompletion
+	// и при ограничении количества повторения перебираются от даты задачи
+	if startDate := s.startDate(exceptions, fromDate); rules.datePart != "" && s.Count == 0 &&
+		mode == RepeatFromSchedule && startDate.After(begDate) {
+		if curDate, err = nextAfter(rules, begDate, startDate.Add(-time.Nanosecond)); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; curDate.Before(endDate); i++ {
+		if i >= maxIterations {
+			return nil, ErrTooManyOccurrences
+		}
+		if s.Count > 0 && count >= s.Count {
+			break
+		}
+		if date := formatDate(curDate, withTime); !exceptions.Skipped(date) {
+			count++
+			date = exceptions.target(date)
+			if occurrence, _, err := parseDateTime(date, loc); err == nil &&
+				!occurrence.Before(fromDate) && occurrence.Before(toDate) {
+				dates = append(dates, date)
+			}
+		}
+		if rules.datePart == "" { // задача без повторений имеет единственную дату
+			break
+		}
+		if mode == RepeatFromCompletion { // отсчёт каждого повторения ведётся от предыдущего
+			begDate = curDate
+		}
+		curDate, err = nextAfter(rules, begDate, curDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(dates)
+
+	return dates, nil
+}
+
+// startDate возвращает начало перебора повторений серии для интервала, начинающегося с fromDate:
+// fromDate либо более ранний исходный день повторения, перенесённого на дату не ранее fromDate
+func (s Series) startDate(exceptions Exceptions, fromDate time.Time) time.Time {
+	if first := exceptions.firstMovedTo(fromDate.Format(settings.DateFormat)); first != "" {
+		if firstDate, err := time.ParseInLocation(settings.DateFormat, first, fromDate.Location()); err == nil &&
+			firstDate.Before(fromDate) {
+			return firstDate
+		}
+	}
+	return fromDate
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+)
+
+// RuleError - ошибка разбора правила повторения с указанием позиции ошибочного слова (токена) правила
+type RuleError struct {
+	Token  int    // индекс токена правила, 0 - обозначение части даты
+	Value  string // значение токена, пустое если токен отсутствует
+	Reason string // причина ошибки
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("%s at token %d", e.Reason, e.Token)
+}
+
+// ruleError создает ошибку разбора правила для токена с индексом token
+func ruleError(tokens []string, token int, format string, args ...any) *RuleError {
+	err := &RuleError{Token: token, Reason: fmt.Sprintf(format, args...)}
+	if token < len(tokens) {
+		err.Value = tokens[token]
+	}
+	return err
+}
+
+// checkArgs проверяет, что количество дополнительных параметров правила rules, полученного из токенов tokens,
+// лежит в диапазоне [minArgs, maxArgs]
+func checkArgs(rules RepeatRules, tokens []string, minArgs int, maxArgs int) error {
+	if len(rules.nums) < minArgs {
+		return ruleError(tokens, len(rules.nums)+1, "missing argument for date part '%s'", rules.datePart)
+	}
+	if len(rules.nums) > maxArgs {
+		return ruleError(tokens, maxArgs+1, "unexpected argument for date part '%s'", rules.datePart)
+	}
+	return nil
+}
+
+// checkRange проверяет, что значения i-го параметра правила rules лежат в диапазоне [low, high], исключая 0
+func checkRange(rules RepeatRules, tokens []string, i int, name string, low int, high int) error {
+	for _, num := range rules.nums[i] {
+		if num < low || num > high || num == 0 {
+			return ruleError(tokens, i+1, "%s %d out of range", name, num)
+		}
+	}
+	return nil
+}
+
+// Validate проверяет интервал правила "d": единственное значение от 1 до 400
+func (dayRule) Validate(rules RepeatRules, tokens []string) error {
+	if err := checkArgs(rules, tokens, 1, 1); err != nil {
+		return err
+	}
+	if len(rules.nums[0]) != 1 {
+		return ruleError(tokens, 1, "only one interval is allowed for date part 'd'")
+	}
+	return checkRange(rules, tokens, 0, "interval", 1, 400)
+}
+
+// Validate проверяет интервал правил "h" и "min": единственное значение от 1 до максимального интервала
+func (r timeRule) Validate(rules RepeatRules, tokens []string) error {
+	if err := checkArgs(rules, tokens, 1, 1); err != nil {
+		return err
+	}
+	if len(rules.nums[0]) != 1 {
+		return ruleError(tokens, 1, "only one interval is allowed for time part '%s'", rules.datePart)
+	}
+	return checkRange(rules, tokens, 0, "interval", 1, r.maxInterval)
+}
+
+// Validate проверяет дни недели правила "w"
+func (weekRule) Validate(rules RepeatRules, tokens []string) error {
+	if err := checkArgs(rules, tokens, 1, 1); err != nil {
+		return err
+	}
+	return checkRange(rules, tokens, 0, "weekday", 1, 7)
+}
+
+// Validate проверяет дни месяца и необязательный список месяцев правила "m"
+func (monthRule) Validate(rules RepeatRules, tokens []string) error {
+	if err := checkArgs(rules, tokens, 1, 2); err != nil {
+		return err
+	}
+	err := checkRange(rules, tokens, 0, "day", -2, 31)
+	if err == nil && len(rules.nums) > 1 {
+		err = checkRange(rules, tokens, 1, "month", 1, 12)
+	}
+	return err
+}
+
+// Validate проверяет номера недель, дни недели и необязательный список месяцев правила "n"
+func (weekdayRule) Validate(rules RepeatRules, tokens []string) error {
+	if err := checkArgs(rules, tokens, 2, 3); err != nil {
+		return err
+	}
+	err := checkRange(rules, tokens, 0, "week number", -5, 5)
+	if err == nil {
+		err = checkRange(rules, tokens, 1, "weekday", 1, 7)
+	}
+	if err == nil && len(rules.nums) > 2 {
+		err = checkRange(rules, tokens, 2, "month", 1, 12)
+	}
+	return err
+}
+
+// Validate проверяет отсутствие числовых параметров правила "y": даты правила проверяются при разборе
+func (yearRule) Validate(rules RepeatRules, tokens []string) error {
+	return checkArgs(rules, tokens, 0, 0)
+}
+
+// UpcomingDates возвращает count ближайших дат повторения задачи с датой начала date по правилу repeat,
+// начиная с даты now включительно, в формате 20060102 или, для дат со временем, "20060102 15:04".
+// Даты разбираются в часовом поясе loc (nil - часовой пояс по умолчанию)
+func UpcomingDates(now string, date string, repeat string, count int, loc *time.Location) ([]string, error) {
+	begDate, withTime, err := parseDateTime(date, loc)
+	if err != nil {
+		return nil, err
+	}
+	nowDate, _, err := parseDateTime(now, loc)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseRepeat(repeat)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := []string{}
+	if rules.datePart == "" {
+		return dates, nil
+	}
+	withTime = withTime || rules.timeBased()
+	// первое повторение не раньше даты now и даты начала; дата начала входит в список, только если подходит под правило
+	startDate := begDate
+	if startDate.Before(nowDate) {
+		startDate = nowDate
+	}
+	before := startDate.AddDate(0, 0, -1)
+	if rules.timeBased() {
+		before = startDate.Add(-time.Minute)
+	}
+	curDate, err := nextAfter(rules, begDate, before)
+	if err != nil {
+		return nil, err
+	}
+	for len(dates) < count {
+		dates = append(dates, formatDate(curDate, withTime))
+		curDate, err = nextAfter(rules, begDate, curDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dates, nil
+}
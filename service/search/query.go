@@ -0,0 +1,205 @@
+// Package search разбирает язык запросов поиска задач:
+//
+//	отчёт               задачи со словом, начинающимся с "отчёт", в названии или комментарии
+//	"годовой отчёт"     задачи с фразой - словами, идущими подряд
+//	-черновик           задачи без слова, начинающегося с "черновик"
+//	title:отчёт         поиск только в названии; comment:"..." - только в комментарии
+//	date:2024-03-01     задачи на дату; due: - синоним date:. Даты в форматах 2024-03-01, 20240301 или 01.03.2024
+//	date:<2024-03-01    сравнение даты задачи: <, <=, >, >=, =
+//	repeat:w            задачи с правилом повторения вида w; repeat:none - задачи без повторения
+//
+// Условия объединяются по И, знак "-" перед любым условием означает отрицание
+package search
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/FausT-VX/todo-list-server/service/scheduler"
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// Поля условий запроса
+const (
+	FieldText    = ""        // название или комментарий задачи
+	FieldTitle   = "title"   // название задачи
+	FieldComment = "comment" // комментарий задачи
+	FieldDate    = "date"    // дата задачи
+	FieldRepeat  = "repeat"  // вид правила повторения задачи
+)
+
+// RepeatNone - значение условия repeat: для задач без повторения
+const RepeatNone = "none"
+
+// fieldAliases - обозначения полей в запросе
+var fieldAliases = map[string]string{
+	"title":   FieldTitle,
+	"comment": FieldComment,
+	"date":    FieldDate,
+	"due":     FieldDate,
+	"repeat":  FieldRepeat,
+}
+
+// dateFormats - допустимые форматы дат в условиях date:
+var dateFormats = []string{time.DateOnly, settings.DateFormat, "02.01.2006"}
+
+// Term - условие запроса
+type Term struct {
+	Field  string   // поле условия: FieldText, FieldTitle, FieldComment, FieldDate или FieldRepeat
+	Negate bool     // отрицание условия
+	Words  []string // слова в нижнем регистре для текстовых полей
+	Phrase bool     // слова образуют фразу и совпадают целиком; иначе каждое слово совпадает с началом слова текста
+	Op     string   // оператор сравнения даты: "=", "<", "<=", ">", ">="
+	Value  string   // дата в формате 20060102 для FieldDate или вид правила повторения для FieldRepeat
+}
+
+// Text определяет, является ли условие поиском слов в тексте задачи
+func (t Term) Text() bool {
+	return t.Field == FieldText || t.Field == FieldTitle || t.Field == FieldComment
+}
+
+// Query - разобранный запрос поиска
+type Query struct {
+	Terms []Term
+}
+
+// TextTerms возвращает условия поиска слов в тексте задачи с отрицанием negate или без него
+func (q Query) TextTerms(negate bool) []Term {
+	var terms []Term
+	for _, term := range q.Terms {
+		if term.Text() && term.Negate == negate {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// ParseError - ошибка разбора запроса с указанием позиции ошибочного условия
+type ParseError struct {
+	Pos    int    // номер символа запроса, начиная с 1
+	Token  string // ошибочное условие запроса
+	Reason string // причина ошибки
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid search query at position %d (%q): %s", e.Pos, e.Token, e.Reason)
+}
+
+// Words разбивает текст text на слова в нижнем регистре. Словами считаются
+// последовательности букв и цифр любых алфавитов, как в токенизаторе FTS5 unicode61
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !IsWordRune(r) })
+}
+
+// IsWordRune определяет, является ли символ r частью слова
+func IsWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// Parse разбирает запрос поиска text. Слова без букв и цифр пропускаются
+func Parse(text string) (Query, error) {
+	var query Query
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		start := i
+		term := Term{}
+		if runes[i] == '-' {
+			term.Negate = true
+			if i++; i == len(runes) || unicode.IsSpace(runes[i]) {
+				return Query{}, &ParseError{Pos: start + 1, Token: "-", Reason: "nothing to negate"}
+			}
+		}
+		// поле условия - латинские буквы перед двоеточием
+		name := i
+		for name < len(runes) && runes[name] < unicode.MaxASCII && unicode.IsLetter(runes[name]) {
+			name++
+		}
+		if name > i && name < len(runes) && runes[name] == ':' {
+			field, ok := fieldAliases[strings.ToLower(string(runes[i:name]))]
+			if !ok {
+				return Query{}, &ParseError{Pos: start + 1, Token: string(runes[start : name+1]),
+					Reason: "unknown field, must be title, comment, date, due or repeat"}
+			}
+			term.Field, i = field, name+1
+		}
+
+		value, end, quoted, err := readValue(runes, i)
+		if err != nil {
+			err.Pos, err.Token = start+1, string(runes[start:end])
+			return Query{}, err
+		}
+		i = end
+		token := string(runes[start:end])
+		if reason := term.set(value, quoted); reason != "" {
+			return Query{}, &ParseError{Pos: start + 1, Token: token, Reason: reason}
+		}
+		if term.Text() && len(term.Words) == 0 {
+			if quoted || term.Field != FieldText || term.Negate {
+				return Query{}, &ParseError{Pos: start + 1, Token: token, Reason: "no words to search"}
+			}
+			continue
+		}
+		query.Terms = append(query.Terms, term)
+	}
+	return query, nil
+}
+
+// readValue читает значение условия, начинающееся с символа runes[i]: фразу в кавычках или слово до пробела.
+// Возвращает значение, индекс символа после значения и признак значения в кавычках
+func readValue(runes []rune, i int) (string, int, bool, *ParseError) {
+	if i < len(runes) && runes[i] == '"' {
+		end := slices.Index(runes[i+1:], '"')
+		if end < 0 {
+			return "", len(runes), true, &ParseError{Reason: "unterminated quoted phrase"}
+		}
+		return string(runes[i+1 : i+1+end]), i + end + 2, true, nil
+	}
+	end := i
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+	if end == i {
+		return "", end, false, &ParseError{Reason: "missing value"}
+	}
+	return string(runes[i:end]), end, false, nil
+}
+
+// set устанавливает значение value условия term в соответствии с его полем.
+// Возвращает причину ошибки; "" - значение допустимо
+func (t *Term) set(value string, quoted bool) string {
+	switch t.Field {
+	case FieldDate:
+		for _, op := range []string{"<=", ">=", "<", ">", "="} {
+			if strings.HasPrefix(value, op) {
+				t.Op, value = op, strings.TrimPrefix(value, op)
+				break
+			}
+		}
+		if t.Op == "" {
+			t.Op = "="
+		}
+		for _, format := range dateFormats {
+			if date, err := time.Parse(format, value); err == nil {
+				t.Value = date.Format(settings.DateFormat)
+				return ""
+			}
+		}
+		return "invalid date, must be in format 2006-01-02, 20060102 or 02.01.2006"
+	case FieldRepeat:
+		t.Value = strings.ToLower(value)
+		if t.Value != RepeatNone && !slices.Contains(scheduler.PossibleVals(), t.Value) {
+			return fmt.Sprintf("unknown repeat rule, must be one of %s or %s",
+				strings.Join(scheduler.PossibleVals(), ", "), RepeatNone)
+		}
+		return ""
+	}
+	t.Words, t.Phrase = Words(value), quoted
+	return ""
+}
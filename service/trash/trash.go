@@ -0,0 +1,32 @@
+// Package trash окончательно удаляет задачи, хранящиеся в корзине дольше срока хранения
+package trash
+
+import (
+	"log"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/database"
+	"github.com/FausT-VX/todo-list-server/settings"
+)
+
+// Purge окончательно удаляет из корзины store задачи, помещенные в нее ранее, чем за retention до момента now.
+// Возвращает количество удаленных задач
+func Purge(store database.TrashRepository, retention time.Duration, now time.Time) (int64, error) {
+	return store.PurgeTrash(now.UTC().Add(-retention).Format(settings.DateTimeFormat))
+}
+
+// Run удаляет из корзины store задачи с истекшим сроком хранения retention сразу и затем каждые interval.
+// Выполняется до завершения программы, поэтому запускается в отдельной горутине
+func Run(store database.TrashRepository, retention time.Duration, interval time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := Purge(store, retention, time.Now())
+		if err != nil {
+			logger.Printf("Trash purge error: %v", err)
+		} else if purged > 0 {
+			logger.Printf("%d tasks have been purged from the trash", purged)
+		}
+		<-ticker.C
+	}
+}
@@ -1,22 +1,47 @@
 package settings
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 // Настройки по умолчанию
 const (
-	DateFormat = "20060102"
-	DBPath     = "./scheduler.db" // Путь к базе данных
-	Port       = ":7540"          // Порт сервера
-	WebDir     = "./web"          // Директория для web файлов
+	DateFormat     = "20060102"
+	TimeFormat     = "15:04"          // Формат времени задачи
+	DateTimeFormat = "20060102 15:04" // Формат даты и времени задачи
+	DBPath         = "./scheduler.db" // Путь к базе данных
+	Port           = ":7540"          // Порт сервера
+	WebDir         = "./web"          // Директория для web файлов
 )
 
 // Лимиты на получение строк в SQL-запросах
 const (
-	Limit50 int = 50
+	Limit50  int = 50   // размер страницы списка задач по умолчанию
+	MaxLimit int = 1000 // наибольший размер страницы списка задач
+)
+
+// Количество ближайших дат повторения, возвращаемых при проверке правила повторения
+const ValidateNextCount int = 5
+
+// Максимальная длина интервала в днях при получении повторений задач
+const MaxIntervalDays int = 366
+
+// Максимальная длина имени пользователя, записываемого в историю изменений задач
+const MaxUserName int = 64
+
+// Корзина удаленных задач
+const (
+	TrashRetention     = 30 * 24 * time.Hour // срок хранения задач в корзине по умолчанию
+	TrashPurgeInterval = time.Hour           // интервал окончательного удаления задач с истекшим сроком хранения
 )
 
-var EnvDBFile = os.Getenv("TODO_DBFILE") // Файл БД из переменной окружения TODO_DBFILE
-var EnvPort = os.Getenv("TODO_PORT")     // Порт из переменной окружения TODO_PORT
-var EnvPass = os.Getenv("TODO_PASSWORD") // Пароль из переменной окружения TODO_PASSWORD
+var EnvDBFile = os.Getenv("TODO_DBFILE")                  // Файл БД из переменной окружения TODO_DBFILE
+var EnvPort = os.Getenv("TODO_PORT")                      // Порт из переменной окружения TODO_PORT
+var EnvPass = os.Getenv("TODO_PASSWORD")                  // Пароль из переменной окружения TODO_PASSWORD
+var EnvCalendar = os.Getenv("TODO_CALENDAR")              // Файл производственного календаря (.json или .ics) из переменной окружения TODO_CALENDAR
+var EnvTZ = os.Getenv("TODO_TZ")                          // Часовой пояс сервера по умолчанию из переменной окружения TODO_TZ
+var EnvDSN = os.Getenv("TODO_DSN")                        // Строка подключения к PostgreSQL из переменной окружения TODO_DSN; "" - база данных SQLite
+var EnvTrashRetention = os.Getenv("TODO_TRASH_RETENTION") // Срок хранения задач в корзине, например 720h, из переменной окружения TODO_TRASH_RETENTION; "0" - хранить всегда
 
 var JwtSecretKey = []byte("very-secret-key")
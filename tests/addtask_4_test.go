@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/FausT-VX/todo-list-server/service/scheduler"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -169,3 +170,142 @@ func TestAddTask(t *testing.T) {
 		check()
 	}
 }
+
+func TestAddTaskRRULE(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now().Format(`20060102`)
+	tbl := []struct {
+		rrule  string
+		repeat string
+		count  int
+		until  string
+	}{
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR", "w 1,3,5", 0, ""},
+		{"FREQ=DAILY;INTERVAL=3;COUNT=4", "d 3", 4, ""},
+		{"FREQ=MONTHLY;BYDAY=2TU,-1TU;UNTIL=20991231T000000Z", "n 2,-1 2", 0, "20991231"},
+		{"FREQ=YEARLY;BYMONTH=1,8;BYMONTHDAY=10,17", "y 10.01,17.01,10.08,17.08", 0, ""},
+		{"FREQ=DAILY;BYDAY=MO", "", 0, ""},
+		{"FREQ=MONTHLY;BYDAY=1MO,3FR", "", 0, ""},
+		{"FREQ=HOURLY;INTERVAL=4", "h 4", 0, ""},
+		{"FREQ=SECONDLY", "", 0, ""},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", "w 1 /2", 0, ""},
+		{"FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=1", "m 1 /3", 0, ""},
+		{"FREQ=YEARLY;INTERVAL=2;BYMONTH=1;BYMONTHDAY=1", "y 01.01 /2", 0, ""},
+		{"FREQ=YEARLY;INTERVAL=2;BYMONTH=1;BYDAY=1MO", "", 0, ""},
+	}
+	for _, v := range tbl {
+		m, err := postJSON("api/task", map[string]any{
+			"date":   now,
+			"title":  "Импорт из календаря",
+			"repeat": v.rrule,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+
+		if v.repeat == "" {
+			assert.NotEmpty(t, m["error"], "Ожидается ошибка для правила %s", v.rrule)
+			continue
+		}
+		e, ok := m["error"]
+		if ok && len(fmt.Sprint(e)) > 0 {
+			t.Errorf("Неожиданная ошибка %v для правила %s", e, v.rrule)
+			continue
+		}
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, fmt.Sprint(m["id"]))
+		assert.NoError(t, err)
+		assert.Equal(t, v.repeat, task.Repeat)
+		assert.Equal(t, v.count, task.RepeatCount)
+		assert.Equal(t, v.until, task.RepeatUntil)
+	}
+
+	// правило без дня месяца повторяется в день месяца даты задачи
+	m, err := postJSON("api/task", map[string]any{
+		"date":   "20240115",
+		"title":  "Оплата аренды",
+		"repeat": "FREQ=MONTHLY",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	if assert.Empty(t, m["error"]) {
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, fmt.Sprint(m["id"]))
+		assert.NoError(t, err)
+		assert.Equal(t, "m 15", task.Repeat)
+		assert.Equal(t, "15", task.Date[6:])
+	}
+}
+
+func TestRRULERoundTrip(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		count  int
+		until  string
+		rrule  string
+	}{
+		{"d 1", 0, "", "FREQ=DAILY"},
+		{"d 7", 3, "", "FREQ=DAILY;INTERVAL=7;COUNT=3"},
+		{"h 4", 0, "", "FREQ=HOURLY;INTERVAL=4"},
+		{"min 30", 0, "20241231", "FREQ=MINUTELY;INTERVAL=30;UNTIL=20241231"},
+		{"w 1,3,5", 0, "", "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{"w 1 /2", 0, "", "FREQ=WEEKLY;BYDAY=MO;INTERVAL=2"},
+		{"m 1,-1", 0, "", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"m 1,15 3", 0, "", "FREQ=MONTHLY;BYMONTHDAY=1,15;BYMONTH=3"},
+		{"m 5 /3", 0, "", "FREQ=MONTHLY;BYMONTHDAY=5;INTERVAL=3"},
+		{"n 2,-1 2", 0, "", "FREQ=MONTHLY;BYDAY=2TU,-1TU"},
+		{"n 1 1,5 1,7", 0, "", "FREQ=MONTHLY;BYDAY=1MO,1FR;BYMONTH=1,7"},
+		{"y", 0, "", "FREQ=YEARLY"},
+		{"y /5", 0, "", "FREQ=YEARLY;INTERVAL=5"},
+		{"y 05.03", 0, "", "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=5"},
+		{"y 05.03 /2", 0, "", "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=5;INTERVAL=2"},
+		{"y 01.01,01.07", 2, "", "FREQ=YEARLY;BYMONTH=1,7;BYMONTHDAY=1;COUNT=2"},
+		{"y -1.02", 0, "", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1"},
+	}
+	for _, v := range tbl {
+		rrule, err := scheduler.ToRRULE(v.repeat, v.count, v.until)
+		if !assert.NoError(t, err, v.repeat) {
+			continue
+		}
+		assert.Equal(t, v.rrule, rrule, v.repeat)
+
+		repeat, count, until, err := scheduler.FromRRULE(rrule, time.Now())
+		assert.NoError(t, err, rrule)
+		assert.Equal(t, v.repeat, repeat, rrule)
+		assert.Equal(t, v.count, count, rrule)
+		assert.Equal(t, v.until, until, rrule)
+	}
+
+	// не заданные день месяца и месяц берутся из даты начала, как DTSTART в RFC 5545
+	start := time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)
+	for rrule, repeat := range map[string]string{
+		"FREQ=MONTHLY":                       "m 15",
+		"FREQ=MONTHLY;INTERVAL=2":            "m 15 /2",
+		"FREQ=MONTHLY;BYMONTH=3,9":           "m 15 3,9",
+		"FREQ=YEARLY":                        "y",
+		"FREQ=YEARLY;BYMONTH=3":              "y 15.03",
+		"FREQ=YEARLY;BYMONTH=6;INTERVAL=2":   "y 15.06 /2",
+		"FREQ=YEARLY;BYMONTHDAY=1,-1":        "y 01.01,-1.01",
+		"FREQ=YEARLY;BYMONTH=1;BYMONTHDAY=5": "y 05.01",
+	} {
+		got, _, _, err := scheduler.FromRRULE(rrule, start)
+		assert.NoError(t, err, rrule)
+		assert.Equal(t, repeat, got, rrule)
+	}
+	_, _, _, err := scheduler.FromRRULE("FREQ=YEARLY;BYDAY=1MO", start)
+	assert.Error(t, err)
+
+	// правила без записи RRULE
+	for _, repeat := range []string{"", "d 1 bd", "w 1 fwd", "y 29.02 feb28", "y 01.01,15.06"} {
+		_, err := scheduler.ToRRULE(repeat, 0, "")
+		assert.Error(t, err, repeat)
+	}
+
+	// задача возвращается с записью правила RRULE для экспорта
+	id := addTask(t, task{date: time.Now().Format(`20060102`), title: "Экспорт в календарь", repeat: "w 2,4"})
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=TU,TH", m["rrule"])
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/service/calendar"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendar(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(`20060102`, s)
+		assert.NoError(t, err)
+		return d
+	}
+
+	cal := calendar.New()
+	assert.True(t, cal.IsWorkday(date("20240126")))
+	assert.False(t, cal.IsWorkday(date("20240127")))
+
+	// производственный календарь: 2 ноября 2024 (суббота) - рабочий день, 4 ноября - праздник
+	err := cal.ReadJSON(strings.NewReader(`{"year": 2024, "months": [
+		{"month": 11, "days": "1*,3,4,9,10,16,17,23,24,30"}
+	]}`))
+	assert.NoError(t, err)
+	assert.True(t, cal.IsWorkday(date("20241101")))
+	assert.True(t, cal.IsWorkday(date("20241102")))
+	assert.False(t, cal.IsWorkday(date("20241104")))
+	assert.True(t, cal.IsWorkday(date("20241105")))
+
+	err = cal.ReadICS(strings.NewReader(`BEGIN:VCALENDAR
+BEGIN:VEVENT
+DTSTART;VALUE=DATE:20250101
+DTEND;VALUE=DATE:20250104
+SUMMARY:Новогодние каникулы
+END:VEVENT
+BEGIN:VEVENT
+DTSTART;VALUE=DATE:20250224
+SUMMARY:Перенос
+END:VEVENT
+END:VCALENDAR`))
+	assert.NoError(t, err)
+	assert.False(t, cal.IsWorkday(date("20250101")))
+	assert.False(t, cal.IsWorkday(date("20250103")))
+	assert.False(t, cal.IsWorkday(date("20250224")))
+	assert.True(t, cal.IsWorkday(date("20250225")))
+
+	assert.Error(t, cal.ReadJSON(strings.NewReader(`{"year": 2024, "months": [{"month": 13, "days": "1"}]}`)))
+}
@@ -6,16 +6,24 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID          int64  `db:"id"`
+	Date        string `db:"date"`
+	Time        string `db:"time"`
+	Title       string `db:"title"`
+	Comment     string `db:"comment"`
+	Repeat      string `db:"repeat"`
+	RepeatCount int    `db:"repeat_count"`
+	RepeatUntil string `db:"repeat_until"`
+	RepeatFrom  string `db:"repeat_from"`
+	Overdue     string `db:"overdue"`
+	TimeZone    string `db:"tz"`
+	Exceptions  string `db:"exceptions"`
+	DeletedAt   string `db:"deleted_at"`
+	CompletedAt string `db:"completed_at"`
 }
 
 func count(db *sqlx.DB) (int, error) {
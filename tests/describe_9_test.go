@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeRepeat(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		lang   string
+		want   string
+	}{
+		{"m 10,17 12,8,1", "en", "10th and 17th of January, August and December"},
+		{"m 10,17 12,8,1", "ru", "10-го и 17-го числа января, августа и декабря"},
+		{"m 1,-1", "en", "1st and last day of every month"},
+		{"m -2", "ru", "предпоследнего числа каждого месяца"},
+		{"d 1", "en", "every day"},
+		{"d 21", "ru", "каждый 21 день"},
+		{"d 3", "ru", "каждые 3 дня"},
+		{"d 12", "ru", "каждые 12 дней"},
+		{"y", "ru", "каждый год"},
+		{"w 5,1,3", "en", "every Monday, Wednesday and Friday"},
+		{"w 3,7", "ru", "по средам и воскресеньям"},
+		{"n 2,-1 2", "en", "the second and last Tuesday of every month"},
+		{"n 2 2,5 1,7", "ru", "во второй вторник и во вторую пятницу января и июля"},
+		{"h 4", "en", "every 4 hours"},
+		{"h 1", "ru", "каждый час"},
+		{"min 21", "ru", "каждую 21 минуту"},
+		{"min 30", "ru", "каждые 30 минут"},
+		{"w 1 /2", "en", "every 2 weeks on Monday"},
+		{"w 1,4 /2", "ru", "по понедельникам и четвергам раз в 2 недели"},
+		{"m 1 /3", "ru", "1-го числа раз в 3 месяца"},
+		{"m 1 1,7 /2", "en", "1st of January and July, every 2 months"},
+		{"y /5", "ru", "раз в 5 лет"},
+		{"y 05.03,-1.02", "en", "every year on the last day of February and March 5"},
+		{"y 05.03,-1.02", "ru", "каждый год в последний день февраля и 5 марта"},
+		{"y 29.02 feb28", "en", "every year on February 29, on February 28 in non-leap years"},
+		{"y 29.02", "ru", "каждый год 29 февраля, в невисокосные годы 1 марта"},
+		{"y 01.06 /2", "ru", "раз в 2 года 1 июня"},
+		{"y 30.02", "ru", ""},
+		{"m 32", "ru", ""},
+		{"k 1", "en", ""},
+	}
+	for _, v := range tbl {
+		body, err := requestJSON("api/repeat/describe?lang="+v.lang+"&repeat="+url.QueryEscape(v.repeat), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]string
+		assert.NoError(t, json.Unmarshal(body, &m))
+		if v.want == "" {
+			assert.NotEmpty(t, m["error"], "Ожидается ошибка для правила %q", v.repeat)
+			continue
+		}
+		assert.Equal(t, v.want, m["description"], "Правило %q", v.repeat)
+	}
+}
@@ -0,0 +1,18 @@
+package tests
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+// Тесты подключаются к базе данных под именем драйвера "sqlite3". Индекс поиска FTS5 обновляется триггерами
+// на таблице scheduler, а драйвер mattn/go-sqlite3 без тега сборки sqlite_fts5 не поддерживает FTS5,
+// поэтому под этим именем регистрируется драйвер modernc.org/sqlite, которым база данных открывается в сервере
+func init() {
+	db, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register("sqlite3", db.Driver())
+}
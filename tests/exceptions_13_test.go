@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskExceptions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	start := time.Now().AddDate(0, 0, 1)
+	day := func(days int) string {
+		return start.AddDate(0, 0, days).Format(`20060102`)
+	}
+	id := addTask(t, task{
+		date:   day(0),
+		title:  "Еженедельная встреча",
+		repeat: "d 7",
+	})
+	getTask := func() Task {
+		var task Task
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		return task
+	}
+	exception := func(date, to string) map[string]any {
+		ret, err := postJSON("api/task/exception", map[string]any{"id": id, "date": date, "to": to}, http.MethodPost)
+		assert.NoError(t, err)
+		return ret
+	}
+
+	// пропуск текущего повторения переносит задачу на следующее
+	assert.Empty(t, exception(day(0), ""))
+	assert.Equal(t, day(7), getTask().Date)
+
+	// перенос текущего повторения и его отмена
+	assert.Empty(t, exception(day(7), day(9)))
+	assert.Equal(t, day(9), getTask().Date)
+	ret, err := postJSON("api/task/exception?id="+id+"&date="+day(7), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, day(7), getTask().Date)
+
+	// перенос будущего повторения
+	assert.Empty(t, exception(day(14), day(15)))
+	assert.NotEmpty(t, exception(day(14), "")["error"])
+	assert.NotEmpty(t, exception("ooops", "")["error"])
+
+	var dates []string
+	for _, v := range getOccurrences(t, day(0), day(28)) {
+		if fmt.Sprint(v["id"]) == id {
+			dates = append(dates, fmt.Sprint(v["date"]))
+		}
+	}
+	assert.Equal(t, []string{day(7), day(15), day(21), day(28)}, dates)
+
+	// выполнение задачи учитывает перенос, а прошедшие исключения удаляются
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, day(15), getTask().Date)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	current := getTask()
+	assert.Equal(t, day(21), current.Date)
+	assert.Empty(t, current.Exceptions)
+
+	// последнее повторение нельзя пропустить
+	_, err = db.Exec(`UPDATE scheduler SET repeat_count = 1 WHERE id = ?`, id)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, exception(day(21), "")["error"])
+	assert.Equal(t, day(21), getTask().Date)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+
+	// исключения допустимы только для повторяющихся задач
+	id = addTask(t, task{date: day(0), title: "Разовая задача"})
+	assert.NotEmpty(t, exception(day(0), "")["error"])
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
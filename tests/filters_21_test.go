@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTasksFilters(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	past := now.AddDate(0, 0, -3).Format(`20060102`)
+	tomorrow := now.AddDate(0, 0, 1).Format(`20060102`)
+	later := now.AddDate(0, 0, 10).Format(`20060102`)
+	_, err = db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, 'Сдать отчёт', 'Бухгалтерия', '')`, past)
+	assert.NoError(t, err)
+	addTask(t, task{date: tomorrow, title: "Бассейн", comment: "Взять полотенце", repeat: "d 7"})
+	addTask(t, task{date: later, title: "Аптека", comment: "Купить бинт для отчёта", repeat: ""})
+
+	titles := func(query url.Values) []string {
+		page := getTasksPage(t, query)
+		assert.Empty(t, page.Error, query.Encode())
+		titles := []string{}
+		for _, task := range page.Tasks {
+			titles = append(titles, fmt.Sprint(task["title"]))
+		}
+		return titles
+	}
+	assert.Equal(t, []string{"Бассейн", "Аптека"}, titles(url.Values{"from": {tomorrow}}))
+	assert.Equal(t, []string{"Сдать отчёт", "Бассейн"}, titles(url.Values{"to": {tomorrow}}))
+	assert.Equal(t, []string{"Бассейн"}, titles(url.Values{"repeating": {"true"}}))
+	assert.Equal(t, []string{"Сдать отчёт", "Аптека"}, titles(url.Values{"repeating": {"false"}}))
+	assert.Equal(t, []string{"Сдать отчёт"}, titles(url.Values{"overdue": {"true"}}))
+	assert.Equal(t, []string{"Аптека"}, titles(url.Values{"overdue": {"false"}, "repeating": {"false"}}))
+	assert.Equal(t, []string{"Аптека", "Бассейн", "Сдать отчёт"}, titles(url.Values{"sort": {"title"}}))
+	assert.Equal(t, []string{"Аптека", "Бассейн", "Сдать отчёт"}, titles(url.Values{"sort": {"date"}, "order": {"desc"}}))
+	assert.Equal(t, []string{"Сдать отчёт"}, titles(url.Values{"search": {"отчёт"}, "in": {"title"}}))
+	assert.Equal(t, []string{"Аптека"}, titles(url.Values{"search": {"отчёт"}, "in": {"comment"}}))
+	assert.Equal(t, []string{"Сдать отчёт", "Аптека"}, titles(url.Values{"search": {"отчёт"}, "sort": {"id"}}))
+
+	for _, query := range []url.Values{
+		{"from": {"01.01.2024"}},
+		{"from": {later}, "to": {tomorrow}},
+		{"repeating": {"maybe"}},
+		{"overdue": {"yes!"}},
+		{"sort": {"comment"}},
+		{"order": {"up"}},
+		{"search": {"отчёт"}, "in": {"repeat"}},
+	} {
+		page := getTasksPage(t, query)
+		assert.NotEmpty(t, page.Error, query.Encode())
+	}
+
+	_, err = db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+}
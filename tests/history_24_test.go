@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getHistory(t *testing.T, query string) map[string]any {
+	body, err := requestJSON("api/history?"+query, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m
+}
+
+func TestHistory(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	completions := func(id string) []map[string]any {
+		var list []map[string]any
+		for _, item := range getHistory(t, "id="+id)["history"].([]any) {
+			list = append(list, item.(map[string]any))
+		}
+		return list
+	}
+
+	// разовая задача после выполнения остается в истории
+	id := addTask(t, task{date: today, title: "Оплатить счёт", comment: "Электричество"})
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	history := completions(id)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, id, fmt.Sprint(history[0]["task_id"]))
+		assert.Equal(t, today, history[0]["date"])
+		assert.Equal(t, "Оплатить счёт", history[0]["title"])
+		assert.Equal(t, "Электричество", history[0]["comment"])
+		assert.Len(t, history[0]["completed_at"], len("20060102 15:04"))
+	}
+	// повторный запрос выполнения не записывает выполнение ещё раз
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	assert.Len(t, completions(id), 1)
+
+	// каждое выполнение повторяющейся задачи записывается с датой повторения по расписанию
+	start := now.AddDate(0, 0, -1).Format(`20060102`)
+	_, err = db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, 'Полить цветы', '', 'd 2')`, start)
+	assert.NoError(t, err)
+	var repeating int64
+	assert.NoError(t, db.Get(&repeating, `SELECT MAX(id) FROM scheduler`))
+	id = fmt.Sprint(repeating)
+	for i := 0; i < 2; i++ {
+		ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	history = completions(id)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "d 2", history[0]["repeat"])
+		assert.Equal(t, start, history[1]["date"])
+		assert.Greater(t, history[0]["date"], history[1]["date"])
+	}
+
+	// фильтр по датам выполнения
+	tomorrow := now.AddDate(0, 0, 1).Format(`20060102`)
+	m := getHistory(t, "id="+id+"&from="+tomorrow)
+	assert.Empty(t, m["history"])
+	m = getHistory(t, "id="+id+"&from="+today+"&to="+today)
+	assert.Len(t, m["history"], 2)
+
+	for _, query := range []string{"from=2024", "from=" + tomorrow + "&to=" + today, "id=ooops"} {
+		m = getHistory(t, query)
+		assert.NotEmpty(t, m["error"], query)
+	}
+}
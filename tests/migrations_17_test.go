@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FausT-VX/todo-list-server/database/migrations"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	latest, err := migrations.Latest(migrations.SQLite)
+	assert.NoError(t, err)
+	list, err := migrations.List(migrations.SQLite)
+	assert.NoError(t, err)
+	for i, migration := range list {
+		assert.Equal(t, i+1, migration.Version, migration.Name)
+		assert.NotEmpty(t, strings.TrimSpace(migration.SQL), migration.Name)
+	}
+	// миграции всех диалектов имеют одинаковые номера версий и названия
+	pgList, err := migrations.List(migrations.Postgres)
+	assert.NoError(t, err)
+	if assert.Len(t, pgList, len(list)) {
+		for i, migration := range pgList {
+			assert.Equal(t, list[i].Version, migration.Version, migration.Name)
+			assert.Equal(t, list[i].Name, migration.Name)
+			assert.NotEmpty(t, strings.TrimSpace(migration.SQL), migration.Name)
+		}
+	}
+
+	// новая база данных создается сразу с последней версией структуры
+	db, err := sqlx.Connect("sqlite", filepath.Join(t.TempDir(), "new.db"))
+	assert.NoError(t, err)
+	applied, err := migrations.Up(db, false)
+	assert.NoError(t, err)
+	assert.Len(t, applied, latest)
+	version, err := migrations.Current(db)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, version)
+	db.Close()
+
+	// база данных, созданная до появления миграций, со столбцами, добавленными до версии 4
+	db, err = sqlx.Connect("sqlite", filepath.Join(t.TempDir(), "legacy.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE scheduler (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date CHAR(8) NOT NULL DEFAULT "",
+		title VARCHAR(128) NOT NULL DEFAULT "",
+		comment VARCHAR(1000) NOT NULL DEFAULT "",
+		repeat VARCHAR(128) NOT NULL DEFAULT "",
+		repeat_count INTEGER NOT NULL DEFAULT 0,
+		repeat_until CHAR(8) NOT NULL DEFAULT "",
+		repeat_from VARCHAR(16) NOT NULL DEFAULT "schedule",
+		time CHAR(5) NOT NULL DEFAULT ""
+	);
+	INSERT INTO scheduler (date, title) VALUES ("20240126", "Старая задача")`)
+	assert.NoError(t, err)
+	columns := func() []string {
+		var names []string
+		assert.NoError(t, db.Select(&names, "SELECT name FROM pragma_table_info('scheduler')"))
+		return names
+	}
+
+	// пробный запуск не изменяет базу данных
+	applied, err = migrations.Up(db, true)
+	assert.NoError(t, err)
+	assert.Len(t, applied, latest-4)
+	assert.Len(t, columns(), 9)
+	version, err = migrations.Current(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	applied, err = migrations.Up(db, false)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, applied) {
+		assert.Equal(t, 5, applied[0].Version)
+	}
+	assert.Len(t, applied, latest-4)
+	assert.Subset(t, columns(), []string{"repeat_from", "tz", "exceptions", "overdue", "time"})
+	version, err = migrations.Current(db)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, version)
+
+	var task Task
+	assert.NoError(t, db.Get(&task, "SELECT * FROM scheduler"))
+	assert.Equal(t, "Старая задача", task.Title)
+	assert.Equal(t, "schedule", task.RepeatFrom)
+
+	statuses, err := migrations.Statuses(db)
+	assert.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotEmpty(t, status.AppliedAt, status.Name)
+	}
+
+	// повторный запуск ничего не применяет
+	applied, err = migrations.Up(db, false)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+	// столбцы, добавленные не по порядку версий, не пропускаются, а приводят к ошибке миграции
+	drift, err := sqlx.Connect("sqlite", filepath.Join(t.TempDir(), "drift.db"))
+	assert.NoError(t, err)
+	defer drift.Close()
+	_, err = drift.Exec(`CREATE TABLE scheduler (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date CHAR(8) NOT NULL DEFAULT "",
+		title VARCHAR(128) NOT NULL DEFAULT "",
+		comment VARCHAR(1000) NOT NULL DEFAULT "",
+		repeat VARCHAR(128) NOT NULL DEFAULT "",
+		tz VARCHAR(64) NOT NULL DEFAULT ""
+	)`)
+	assert.NoError(t, err)
+	_, err = migrations.Up(drift, false)
+	assert.ErrorContains(t, err, "migration 5_time_zone")
+	version, err = migrations.Current(drift)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, version)
+}
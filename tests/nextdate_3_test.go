@@ -74,6 +74,119 @@ func TestNextDate(t *testing.T) {
 		{"20240126", "w 7", "20240128"},
 		{"20230126", "w 4,5", "20240201"},
 		{"20230226", "w 8,4,5", ""},
+		{"20240126", "n 2 2", "20240213"},
+		{"20240101", "n -1 5", "20240223"},
+		{"20240101", "n 1,3 1 3,6", "20240304"},
+		{"20240126", "n -5 4 1,2,3", "20240201"},
+		{"20240126", "n 6 1", ""},
+		{"20240126", "n 1 8", ""},
+		{"20240126", "n 1 1 13", ""},
+		{"20240126", "n 1", ""},
+		{"20240125", "d 3 bd", "20240130"},
+		{"20240101", "m 3 fwd", "20240205"},
+		{"20240101", "m 3 bwd", "20240202"},
+		{"20240101", "m 28 bwd", "20240228"},
+		{"20240101", "m 27 fwd", "20240129"},
+		{"20240126", "w 1 bd", ""},
+		{"20240126", "d 3 fwd bwd", ""},
+		{"20240126", "d 3 bd 5", ""},
+		{"20240101", "w 1 /2", "20240129"},
+		{"20240108", "w 1 /2", "20240205"},
+		{"20231225", "w 1 /3", "20240205"},
+		{"20231101", "m 1 /3", "20240201"},
+		{"20231015", "m 15 /3", "20240415"},
+		{"20231201", "n 1 1 /2", "20240205"},
+		{"20220301", "y /2", "20240301"},
+		{"20230301", "y /2", "20250301"},
+		{"20240126", "d 3 /2", ""},
+		{"20240126", "w 1 /0", ""},
+		{"20240126", "w 1 /2 /3", ""},
+		{"20240126", "w 1 /x", ""},
+		{"20240126", "w /2 1", ""},
 	}
 	check()
 }
+
+func TestNextDateTime(t *testing.T) {
+	tbl := []struct {
+		now    string
+		date   string
+		repeat string
+		want   string
+	}{
+		{"20240126 12:00", "20240126 10:00", "h 4", "20240126 14:00"},
+		{"20240126 14:00", "20240126 10:00", "h 4", "20240126 18:00"},
+		{"20240126 23:30", "20240126 10:00", "h 4", "20240127 02:00"},
+		{"20240126 12:00", "20240127 10:00", "h 4", "20240127 14:00"},
+		{"20240126 12:10", "20240126 12:00", "min 15", "20240126 12:15"},
+		{"20240126 12:10", "20240126", "h 1", "20240126 13:00"},
+		{"20240126 12:00", "20240120 10:00", "d 7", "20240127 10:00"},
+		{"20240126 12:00", "20240126 10:00", "w 1,5", "20240129 10:00"},
+		{"20240126", "20240101 09:30", "m 1", "20240201 09:30"},
+		{"20240126", "20240120", "d 7", "20240127"},
+		{"20240126 12:00", "20240126 10:00", "h 169", ""},
+		{"20240126 12:00", "20240126 10:00", "min 0", ""},
+		{"20240126 12:00", "20240126 10:00", "h 2 fwd", ""},
+		{"20240126 12:00", "20240126 25:00", "h 2", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			url.QueryEscape(v.now), url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		if strings.Contains(next, "error") && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q}`, v.now, v.date, v.repeat, v.want)
+	}
+}
+
+func TestNextDateYearly(t *testing.T) {
+	tbl := []struct {
+		now    string
+		date   string
+		repeat string
+		want   string
+	}{
+		{"20240126", "20240101", "y 05.03", "20240305"},
+		{"20240126", "20240101", "y 05.03,-1.02", "20240229"},
+		{"20240126", "20240301", "y -1.02", "20250228"},
+		{"20240126", "20240301", "y -2.02", "20250227"},
+		{"20240126", "20240101", "y 10.01,20.01", "20250110"},
+		{"20240126", "20240101", "y 31.12,01.06", "20240601"},
+		{"20240126", "20240101", "y 29.02", "20240229"},
+		{"20240126", "20240301", "y 29.02", "20250301"},
+		{"20240126", "20240301", "y 29.02 mar1", "20250301"},
+		{"20240126", "20240301", "y 29.02 feb28", "20250228"},
+		{"20250301", "20240301", "y 29.02 feb28", "20260228"},
+		{"20270301", "20240301", "y 29.02 feb28", "20280229"},
+		{"20990301", "20240301", "y 29.02 feb28", "21000228"},
+		{"20240126", "20240229", "y feb28", "20250228"},
+		{"20240126", "20240229", "y mar1", "20250301"},
+		{"20240126", "20200229", "y feb28 /4", "20240229"},
+		{"20240126", "20230101", "y 01.06 /2", "20250601"},
+		{"20240126", "20240101", "y 15.06 fwd", "20240617"},
+		{"20240126", "20240126", "y 30.02", ""},
+		{"20240126", "20240126", "y 31.04", ""},
+		{"20240126", "20240126", "y 05.13", ""},
+		{"20240126", "20240126", "y 0.01", ""},
+		{"20240126", "20240126", "y -3.02", ""},
+		{"20240126", "20240126", "y 5", ""},
+		{"20240126", "20240126", "y 05.03 06.03", ""},
+		{"20240126", "20240126", "y feb28 mar1", ""},
+		{"20240126", "20240126", "y feb28 05.03", ""},
+		{"20240126", "20240126", "m 1 feb28", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			url.QueryEscape(v.now), url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		if strings.Contains(next, "error") && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q}`, v.now, v.date, v.repeat, v.want)
+	}
+}
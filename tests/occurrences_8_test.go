@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/service/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func getOccurrences(t *testing.T, from, to string) []map[string]any {
+	body, err := requestJSON("api/occurrences?from="+from+"&to="+to, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m map[string][]map[string]any
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m["occurrences"]
+}
+
+func TestOccurrences(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// ближайший понедельник не раньше чем через неделю
+	monday := time.Now().AddDate(0, 0, 7)
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	from := monday.Format(`20060102`)
+	to := monday.AddDate(0, 0, 13).Format(`20060102`)
+
+	weekly := addTask(t, task{
+		date:   from,
+		title:  "Пробежка",
+		repeat: "w 1,3,5",
+	})
+	ret, err := postJSON("api/task", map[string]any{
+		"date":         from,
+		"title":        "Капать капли",
+		"repeat":       "d 2",
+		"repeat_count": 3,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	limited := fmt.Sprint(ret["id"])
+
+	byTask := map[string][]string{}
+	projected := map[string]bool{}
+	for _, v := range getOccurrences(t, from, to) {
+		id := fmt.Sprint(v["id"])
+		date := fmt.Sprint(v["date"])
+		byTask[id] = append(byTask[id], date)
+		projected[id+date] = v["projected"] == true
+	}
+
+	want := []string{}
+	for _, days := range []int{0, 2, 4, 7, 9, 11} {
+		want = append(want, monday.AddDate(0, 0, days).Format(`20060102`))
+	}
+	assert.Equal(t, want, byTask[weekly])
+	assert.False(t, projected[weekly+from])
+	assert.True(t, projected[weekly+want[1]])
+
+	want = []string{}
+	for _, days := range []int{0, 2, 4} {
+		want = append(want, monday.AddDate(0, 0, days).Format(`20060102`))
+	}
+	assert.Equal(t, want, byTask[limited])
+
+	body, err := requestJSON("api/occurrences?from="+to+"&to="+from, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.NotEmpty(t, m["error"])
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id IN (?, ?)`, weekly, limited)
+	assert.NoError(t, err)
+}
+
+func TestOccurrencesLongSeries(t *testing.T) {
+	// повторения ищутся с начала интервала, а не с даты задачи
+	series := scheduler.Series{Date: "20240101 00:00", Repeat: "min 1", Location: time.UTC}
+	dates, err := series.Occurrences("20240601", "20240601")
+	assert.NoError(t, err)
+	if assert.Len(t, dates, 24*60) {
+		assert.Equal(t, "20240601 00:00", dates[0])
+		assert.Equal(t, "20240601 23:59", dates[len(dates)-1])
+	}
+
+	series = scheduler.Series{Date: "20240103", Repeat: "d 7", Exceptions: "20240110,20240117>20240603", Location: time.UTC}
+	dates, err = series.Occurrences("20240601", "20240610")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"20240603", "20240605"}, dates)
+
+	// при превышении ограничения перебора возвращается ошибка, а не часть повторений
+	series = scheduler.Series{Date: "20240101 00:00", Repeat: "min 1", Location: time.UTC}
+	_, err = series.Occurrences("20240601", "20240901")
+	assert.ErrorIs(t, err, scheduler.ErrTooManyOccurrences)
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getMissed(t *testing.T, id int64) []map[string]any {
+	body, err := requestJSON(fmt.Sprintf("api/missed?id=%d", id), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m map[string][]map[string]any
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m["missed"]
+}
+
+func TestDoneOverdue(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(days int) string {
+		return now.AddDate(0, 0, days).Format(`20060102`)
+	}
+	for _, overdue := range []string{"skip", "step", "record"} {
+		res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat, overdue)
+		VALUES (?, 'Зарядка', '', 'd 1', ?)`, day(-14), overdue)
+		assert.NoError(t, err)
+		id, _ := res.LastInsertId()
+
+		ret, err := postJSON(fmt.Sprintf("api/task/done?id=%d", id), nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		missed := getMissed(t, id)
+		switch overdue {
+		case "step":
+			assert.Equal(t, day(-13), task.Date, overdue)
+			assert.Empty(t, missed, overdue)
+		case "skip":
+			assert.Greater(t, task.Date, day(0), overdue)
+			assert.Empty(t, missed, overdue)
+		case "record":
+			assert.Greater(t, task.Date, day(0), overdue)
+			// пропущены все повторения между выполненным и новой датой задачи, последние идут первыми
+			if assert.NotEmpty(t, missed, overdue) {
+				assert.Equal(t, day(-13), missed[len(missed)-1]["date"])
+				last, _ := time.Parse(`20060102`, fmt.Sprint(missed[0]["date"]))
+				assert.Equal(t, task.Date, last.AddDate(0, 0, 1).Format(`20060102`))
+				assert.Equal(t, "missed", missed[0]["status"])
+				assert.Equal(t, "Зарядка", missed[0]["title"])
+			}
+		}
+
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+		_, err = db.Exec(`DELETE FROM history WHERE task_id = ?`, id)
+		assert.NoError(t, err)
+	}
+
+	// повторения, через которые перешла просроченная задача, учитываются в количестве повторений
+	for _, count := range []int{30, 5} {
+		res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat, repeat_count, overdue)
+		VALUES (?, 'Зарядка', '', 'd 1', ?, 'skip')`, day(-14), count)
+		assert.NoError(t, err)
+		id, _ := res.LastInsertId()
+
+		ret, err := postJSON(fmt.Sprintf("api/task/done?id=%d", id), nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		if count == 5 {
+			// все оставшиеся повторения просрочены
+			assert.NotEmpty(t, task.CompletedAt)
+		} else {
+			start, _ := time.Parse(`20060102`, day(-14))
+			next, _ := time.Parse(`20060102`, task.Date)
+			assert.Equal(t, count-int(next.Sub(start).Hours()/24), task.RepeatCount)
+		}
+
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+		_, err = db.Exec(`DELETE FROM completions WHERE task_id = ?`, id)
+		assert.NoError(t, err)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"title":   "Неизвестная политика",
+		"repeat":  "d 7",
+		"overdue": "ooops",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
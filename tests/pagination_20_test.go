@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tasksPage struct {
+	Tasks      []map[string]any `json:"tasks"`
+	NextCursor *string          `json:"next_cursor"`
+	Total      *int             `json:"total"`
+	Error      string           `json:"error"`
+}
+
+func getTasksPage(t *testing.T, query url.Values) tasksPage {
+	body, err := requestJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var page tasksPage
+	assert.NoError(t, json.Unmarshal(body, &page))
+	return page
+}
+
+func TestTasksPagination(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	// задач больше, чем помещается в список по умолчанию; половина задач на одну дату
+	now := time.Now()
+	count := 60
+	for i := 0; i < count; i++ {
+		addTask(t, task{
+			date:  now.AddDate(0, 0, 1+i%2*i).Format(`20060102`),
+			title: fmt.Sprintf("Задача %d", i),
+		})
+	}
+
+	// без параметров формат ответа прежний
+	page := getTasksPage(t, nil)
+	assert.Len(t, page.Tasks, 50)
+	assert.Nil(t, page.NextCursor)
+	assert.Nil(t, page.Total)
+
+	ids := map[string]bool{}
+	query := url.Values{"limit": {"25"}}
+	for pages := 0; ; pages++ {
+		page := getTasksPage(t, query)
+		if !assert.Empty(t, page.Error) || !assert.NotNil(t, page.NextCursor) || !assert.Less(t, pages, 3) {
+			break
+		}
+		assert.Equal(t, count, *page.Total)
+		for _, task := range page.Tasks {
+			id := fmt.Sprint(task["id"])
+			assert.False(t, ids[id], "task %s is repeated", id)
+			ids[id] = true
+		}
+		if *page.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", *page.NextCursor)
+	}
+	assert.Len(t, ids, count)
+
+	// задачи на дату также возвращаются страницами
+	query = url.Values{"limit": {"20"}, "search": {now.AddDate(0, 0, 1).Format(`02.01.2006`)}}
+	page = getTasksPage(t, query)
+	assert.Len(t, page.Tasks, 20)
+	if assert.NotNil(t, page.Total) && assert.NotNil(t, page.NextCursor) {
+		assert.Equal(t, count/2, *page.Total)
+		query.Set("cursor", *page.NextCursor)
+		page = getTasksPage(t, query)
+		assert.Len(t, page.Tasks, count/2-20)
+		assert.Equal(t, "", *page.NextCursor)
+	}
+
+	for _, query := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"abc"}},
+		{"limit": {"100000"}},
+		{"cursor": {"abc"}},
+	} {
+		page := getTasksPage(t, query)
+		assert.NotEmpty(t, page.Error, query.Encode())
+	}
+
+	_, err = db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+}
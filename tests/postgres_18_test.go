@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/database"
+	"github.com/FausT-VX/todo-list-server/database/migrations"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPostgres проверяет хранилище PostgreSQL в тестовой базе данных из переменной окружения TODO_TEST_PG_DSN.
+// Таблицы создаются миграциями в отдельной схеме, которая удаляется после теста, поэтому повторные запуски
+// не зависят от данных предыдущих. В CI (задана переменная CI) тест без TODO_TEST_PG_DSN завершается ошибкой
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("TODO_TEST_PG_DSN")
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatal("TODO_TEST_PG_DSN is not set: PostgreSQL storage is not tested")
+		}
+		t.Skip("TODO_TEST_PG_DSN is not set: PostgreSQL storage is not tested, see README")
+	}
+
+	db := postgresSchema(t, dsn)
+	latest, err := migrations.Latest(migrations.Postgres)
+	assert.NoError(t, err)
+	version, err := migrations.Current(db)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, version)
+
+	// повторное применение миграций не изменяет базу данных
+	applied, err := migrations.Up(db, false)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	var indexes int
+	assert.NoError(t, db.Get(&indexes, `SELECT COUNT(*) FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = 'scheduler' AND indexname = 'scheduler_date'`))
+	assert.Equal(t, 1, indexes)
+
+	testRepository(t, func(t *testing.T) database.Repository {
+		_, err := db.Exec("TRUNCATE scheduler, history, completions, revisions RESTART IDENTITY")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database.NewPostgresStore(db)
+	})
+}
+
+// postgresSchema создает в базе данных dsn новую схему, применяет к ней миграции и возвращает подключение,
+// в котором эта схема используется по умолчанию. Схема удаляется по завершении теста t
+func postgresSchema(t *testing.T, dsn string) *sqlx.DB {
+	admin, err := database.OpenPostgres(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("todo_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// параметр search_path передаётся драйвером серверу при подключении
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := database.ConnectPostgres(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
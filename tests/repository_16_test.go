@@ -0,0 +1,580 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/database"
+	"github.com/FausT-VX/todo-list-server/database/migrations"
+	"github.com/FausT-VX/todo-list-server/handlers"
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/FausT-VX/todo-list-server/service/trash"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// testRepository проверяет соответствие хранилищ, создаваемых newRepo, поведению, ожидаемому обработчиками.
+// Каждая возможность хранилища проверяется в отдельном подтесте на новом пустом хранилище
+func testRepository(t *testing.T, newRepo func(t *testing.T) database.Repository) {
+	for _, test := range []struct {
+		name string
+		run  func(t *testing.T, repo database.Repository)
+	}{
+		{"tasks", testRepositoryTasks},
+		{"search", testRepositorySearch},
+		{"filters", testRepositoryFilters},
+		{"search query", testRepositorySearchQuery},
+		{"pagination", testRepositoryPagination},
+		{"history", testRepositoryHistory},
+		{"completions", testRepositoryCompletions},
+		{"trash", testRepositoryTrash},
+		{"revisions", testRepositoryRevisions},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newRepo(t))
+		})
+	}
+}
+
+// repositoryTasks возвращает задачи для проверки хранилищ: Review, Купить молоко, Зарядка, Отчёт за неделю и Отчёт
+func repositoryTasks() []models.Task {
+	return []models.Task{
+		{Date: "20240202", Time: "10:00", Title: "Review", Comment: "Code review", Repeat: "d 1",
+			RepeatCount: 3, RepeatUntil: "20240301", RepeatFrom: "schedule", Overdue: "skip", TimeZone: "UTC",
+			Exceptions: "20240203"},
+		{Date: "20240201", Title: "Купить молоко", Comment: "Магазин у дома", RepeatFrom: "schedule", Overdue: "skip"},
+		{Date: "20240202", Time: "09:00", Title: "Зарядка", Repeat: "h 24", RepeatFrom: "schedule", Overdue: "record"},
+		{Date: "20240101", Title: "Отчёт за неделю", RepeatFrom: "schedule", Overdue: "skip"},
+		{Date: "20240301", Title: "Отчёт", Comment: "Сдать отчёт до пятницы", RepeatFrom: "schedule", Overdue: "skip"},
+	}
+}
+
+// insertTasks добавляет задачи tasks в хранилище repo и возвращает их с присвоенными ID
+func insertTasks(t *testing.T, repo database.Repository, tasks ...models.Task) []models.Task {
+	added := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		id, err := repo.InsertTask(task)
+		assert.NoError(t, err)
+		task.ID = fmt.Sprint(id)
+		added = append(added, task)
+	}
+	return added
+}
+
+// insertReports добавляет в хранилище repo задачи repositoryTasks, кроме задачи "Купить молоко"
+func insertReports(t *testing.T, repo database.Repository) []models.Task {
+	tasks := repositoryTasks()
+	return insertTasks(t, repo, tasks[0], tasks[2], tasks[3], tasks[4])
+}
+
+// completeOccurrence записывает в хранилище repo выполнение задачи task на её текущую дату в момент completedAt
+// с пропущенными повторениями missed, переносит задачу на дату next и возвращает перенесённую задачу
+func completeOccurrence(t *testing.T, repo database.Repository, task models.Task, completedAt string, next string,
+	missed ...models.HistoryEntry) models.Task {
+	completion := models.Completion{TaskID: task.ID, Date: task.Date, Time: task.Time, CompletedAt: completedAt,
+		Title: task.Title, Comment: task.Comment, Repeat: task.Repeat}
+	task.Date = next
+	assert.NoError(t, repo.CompleteTask(completion, missed, &task))
+	return task
+}
+
+// listTitles возвращает названия задач, полученных из хранилища repo по запросу query
+func listTitles(t *testing.T, repo database.Repository, query database.TaskQuery) []string {
+	page, err := repo.GetTasks(query)
+	assert.NoError(t, err, query)
+	titles := []string{}
+	for _, task := range page.Tasks {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
+
+// searchTitles возвращает названия задач, найденных в хранилище repo по запросу поиска value
+func searchTitles(t *testing.T, repo database.Repository, value string) []string {
+	return listTitles(t, repo, database.TaskQuery{Search: value})
+}
+
+func testRepositoryTasks(t *testing.T, repo database.Repository) {
+	page, err := repo.GetTasks(database.TaskQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Tasks)
+	assert.Zero(t, page.Total)
+
+	_, err = repo.GetTaskByID(1)
+	assert.EqualError(t, err, "task not found")
+	assert.EqualError(t, repo.DeleteTaskByID(1), "task not found")
+	assert.EqualError(t, repo.UpdateTask(models.Task{ID: "1", Title: "Нет такой задачи"}), "task not found")
+
+	added := insertTasks(t, repo, repositoryTasks()[:3]...)
+	task, err := repo.GetTaskByID(mustAtoi(t, added[0].ID))
+	assert.NoError(t, err)
+	assert.Equal(t, added[0], task)
+
+	// задачи упорядочены по дате и времени
+	page, err = repo.GetTasks(database.TaskQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Task{added[1], added[2], added[0]}, page.Tasks)
+	assert.Equal(t, 3, page.Total)
+	assert.Empty(t, page.NextCursor)
+
+	added[1].Title, added[1].Date = "Купить хлеб", "20240205"
+	assert.NoError(t, repo.UpdateTask(added[1]))
+	task, err = repo.GetTaskByID(mustAtoi(t, added[1].ID))
+	assert.NoError(t, err)
+	assert.Equal(t, added[1], task)
+	assert.Equal(t, []string{"Купить хлеб"}, searchTitles(t, repo, "хлеб"))
+	assert.Empty(t, searchTitles(t, repo, "молоко"))
+
+	assert.NoError(t, repo.DeleteTaskByID(mustAtoi(t, added[1].ID)))
+	_, err = repo.GetTaskByID(mustAtoi(t, added[1].ID))
+	assert.EqualError(t, err, "task not found")
+	assert.Empty(t, searchTitles(t, repo, "хлеб"))
+}
+
+func testRepositorySearch(t *testing.T, repo database.Repository) {
+	insertTasks(t, repo, repositoryTasks()[:3]...)
+	search := func(value string) []string {
+		return searchTitles(t, repo, value)
+	}
+	assert.Equal(t, []string{"Зарядка", "Review"}, search("02.02.2024"))
+	assert.Equal(t, []string{"Review"}, search("REVIEW"))
+	// поиск по словам без учета регистра для любых алфавитов и по началу слов
+	assert.Equal(t, []string{"Купить молоко"}, search("дома"))
+	assert.Equal(t, []string{"Купить молоко"}, search("ДОМА"))
+	assert.Equal(t, []string{"Купить молоко"}, search("мол"))
+	assert.Equal(t, []string{"Купить молоко"}, search("купить, дом!"))
+	assert.Empty(t, search("олоко"))
+	assert.Empty(t, search("купить review"))
+	assert.Empty(t, search("!!!"))
+
+	page, err := repo.GetTasks(database.TaskQuery{Search: "молоко"})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Tasks, 1) {
+		assert.Equal(t, "Купить <mark>молоко</mark>", page.Tasks[0].Snippet)
+	}
+
+	// задачи с большим количеством совпадений находятся раньше
+	insertTasks(t, repo, repositoryTasks()[3:]...)
+	assert.Equal(t, []string{"Отчёт", "Отчёт за неделю"}, search("отчёт"))
+
+	// буквы с диакритическими знаками не приравниваются к буквам без них
+	insertTasks(t, repo, models.Task{Date: "20240204", Title: "Ёлка", RepeatFrom: "schedule", Overdue: "skip"},
+		models.Task{Date: "20240205", Title: "Café", RepeatFrom: "schedule", Overdue: "skip"})
+	assert.Equal(t, []string{"Ёлка"}, search("ёлк"))
+	assert.Empty(t, search("елка"))
+	assert.Equal(t, []string{"Café"}, search("CAFÉ"))
+	assert.Empty(t, search("cafe"))
+
+	// текст фрагмента экранируется для HTML, кроме тегов выделения совпадений
+	insertTasks(t, repo, models.Task{Date: "20240206", Title: "Акт <img src=x onerror=alert(1)> & счёт",
+		RepeatFrom: "schedule", Overdue: "skip"})
+	page, err = repo.GetTasks(database.TaskQuery{Search: "акт"})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Tasks, 1) {
+		snippet := page.Tasks[0].Snippet
+		assert.True(t, strings.HasPrefix(snippet, "<mark>Акт</mark>"), snippet)
+		assert.Contains(t, snippet, "&amp;")
+		text := strings.NewReplacer(database.HighlightStart, "", database.HighlightEnd, "").Replace(snippet)
+		assert.NotContains(t, text, "<")
+		assert.NotContains(t, text, ">")
+	}
+}
+
+func testRepositoryFilters(t *testing.T, repo database.Repository) {
+	insertReports(t, repo)
+	yes, no := true, false
+	list := func(query database.TaskQuery) []string {
+		return listTitles(t, repo, query)
+	}
+	assert.Equal(t, []string{"Зарядка", "Review"}, list(database.TaskQuery{From: "20240201", To: "20240228"}))
+	assert.Equal(t, []string{"Зарядка", "Review", "Отчёт"}, list(database.TaskQuery{From: "20240202"}))
+	assert.Equal(t, []string{"Зарядка", "Review"}, list(database.TaskQuery{Repeating: &yes}))
+	assert.Equal(t, []string{"Отчёт за неделю", "Отчёт"}, list(database.TaskQuery{Repeating: &no}))
+	assert.Equal(t, []string{"Отчёт за неделю", "Зарядка"}, list(database.TaskQuery{Overdue: &yes, Now: "20240202 09:30"}))
+	assert.Equal(t, []string{"Review", "Отчёт"}, list(database.TaskQuery{Overdue: &no, Now: "20240202 09:30"}))
+	assert.Equal(t, []string{"Review", "Зарядка", "Отчёт", "Отчёт за неделю"}, list(database.TaskQuery{Sort: database.SortTitle}))
+	assert.Equal(t, []string{"Отчёт", "Отчёт за неделю", "Зарядка", "Review"}, list(database.TaskQuery{Sort: database.SortID, Desc: true}))
+	assert.Equal(t, []string{"Отчёт", "Review", "Зарядка", "Отчёт за неделю"}, list(database.TaskQuery{Sort: database.SortDate, Desc: true}))
+	assert.ElementsMatch(t, []string{"Отчёт", "Отчёт за неделю"}, list(database.TaskQuery{Search: "отчёт", SearchIn: database.SearchTitle}))
+	assert.Equal(t, []string{"Отчёт"}, list(database.TaskQuery{Search: "отчёт", SearchIn: database.SearchComment}))
+	assert.Empty(t, list(database.TaskQuery{Search: "сдать", SearchIn: database.SearchTitle}))
+	assert.Equal(t, []string{"Отчёт за неделю", "Отчёт"}, list(database.TaskQuery{Search: "отчёт", Sort: database.SortDate}))
+	assert.Equal(t, []string{"Отчёт"}, list(database.TaskQuery{Search: "отчёт", Repeating: &no, From: "20240201"}))
+	page, err := repo.GetTasks(database.TaskQuery{Search: "сдать", SearchIn: database.SearchComment})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Tasks, 1) {
+		assert.Equal(t, "<mark>Сдать</mark> отчёт до пятницы", page.Tasks[0].Snippet)
+	}
+
+	for _, query := range []database.TaskQuery{
+		{Sort: "ooops"}, {SearchIn: "ooops"}, {From: "2024"}, {From: "20240301", To: "20240201"}, {Overdue: &yes, Now: "ooops"},
+	} {
+		_, err = repo.GetTasks(query)
+		assert.Error(t, err, query)
+	}
+}
+
+// testRepositorySearchQuery проверяет язык запросов поиска: фразы, отрицание, поля и сравнение дат
+func testRepositorySearchQuery(t *testing.T, repo database.Repository) {
+	insertReports(t, repo)
+	search := func(value string) []string {
+		return searchTitles(t, repo, value)
+	}
+	assert.Equal(t, []string{"Отчёт"}, search(`"отчёт до"`))
+	assert.Empty(t, search(`"до отчёт"`))
+	assert.Empty(t, search(`"отч"`))
+	assert.Equal(t, []string{"Отчёт за неделю"}, search("отчёт -сдать"))
+	assert.Equal(t, []string{"Зарядка", "Review"}, search("-отчёт"))
+	assert.Equal(t, []string{"Отчёт"}, search("-title:неделю comment:отчёт"))
+	assert.Empty(t, search("comment:неделю"))
+	assert.Equal(t, []string{"Review"}, search("repeat:d"))
+	assert.Equal(t, []string{"Отчёт за неделю", "Отчёт"}, search("repeat:none"))
+	assert.Equal(t, []string{"Зарядка", "Review"}, search("-repeat:NONE"))
+	assert.Equal(t, []string{"Отчёт за неделю"}, search("due:<2024-02-02"))
+	assert.Equal(t, []string{"Отчёт"}, search("date:>=01.03.2024 отчёт"))
+	assert.Equal(t, []string{"Review"}, search("date:20240202 -repeat:h"))
+	page, err := repo.GetTasks(database.TaskQuery{Search: `repeat:none "сдать отчёт"`})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Tasks, 1) {
+		// слова фразы выделяются вместе или по отдельности в зависимости от хранилища
+		assert.Equal(t, "<mark>Сдать отчёт</mark> до пятницы", strings.ReplaceAll(page.Tasks[0].Snippet, "</mark> <mark>", " "))
+	}
+	page, err = repo.GetTasks(database.TaskQuery{Search: "-сдать"})
+	assert.NoError(t, err)
+	for _, task := range page.Tasks {
+		assert.Empty(t, task.Snippet)
+	}
+	for _, value := range []string{"title:", `"отчёт`, "owner:me", "date:<2024", "repeat:x", "отчёт -", `-"!!!"`} {
+		_, err = repo.GetTasks(database.TaskQuery{Search: value})
+		if assert.Error(t, err, value) {
+			assert.Contains(t, err.Error(), "invalid search query", value)
+		}
+	}
+}
+
+// testRepositoryPagination проверяет, что постраничное получение задач возвращает те же задачи,
+// что и получение одной страницей
+func testRepositoryPagination(t *testing.T, repo database.Repository) {
+	insertReports(t, repo)
+	no := false
+	for _, query := range []database.TaskQuery{
+		{}, {Search: "02.02.2024"}, {Search: "отчёт"}, {Sort: database.SortTitle, Desc: true}, {Sort: database.SortID},
+		{Search: "отчёт", Sort: database.SortDate, Desc: true}, {Repeating: &no, Sort: database.SortTitle},
+		{Search: "-сдать"}, {Search: "repeat:none отчёт"},
+	} {
+		all, err := repo.GetTasks(query)
+		assert.NoError(t, err)
+		var paged []models.Task
+		for query.Limit = 1; ; {
+			page, err := repo.GetTasks(query)
+			if !assert.NoError(t, err) || !assert.LessOrEqual(t, len(page.Tasks), 1) {
+				break
+			}
+			assert.Equal(t, all.Total, page.Total)
+			paged = append(paged, page.Tasks...)
+			if query.Cursor = page.NextCursor; query.Cursor == "" || len(paged) > all.Total {
+				break
+			}
+		}
+		assert.Equal(t, all.Tasks, paged, query)
+	}
+	_, err := repo.GetTasks(database.TaskQuery{Cursor: "ooops"})
+	assert.ErrorIs(t, err, database.ErrInvalidCursor)
+	page, err := repo.GetTasks(database.TaskQuery{Limit: 1})
+	assert.NoError(t, err)
+	_, err = repo.GetTasks(database.TaskQuery{Search: "отчёт", Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, database.ErrInvalidCursor)
+}
+
+// testRepositoryHistory проверяет, что история повторений возвращается начиная с последних записей
+func testRepositoryHistory(t *testing.T, repo database.Repository) {
+	added := insertReports(t, repo)
+	review, workout := added[0], added[1]
+	completeOccurrence(t, repo, workout, "20240205 09:15", "20240205",
+		models.HistoryEntry{TaskID: workout.ID, Date: "20240203", Time: "09:00", Title: "Зарядка", Status: models.HistoryMissed},
+		models.HistoryEntry{TaskID: workout.ID, Date: "20240204", Time: "09:00", Title: "Зарядка", Status: models.HistoryMissed})
+	completeOccurrence(t, repo, review, "20240204 10:15", "20240204",
+		models.HistoryEntry{TaskID: review.ID, Date: "20240203", Time: "10:00", Title: "Review", Status: models.HistoryMissed})
+	history, err := repo.GetHistory(mustAtoi(t, workout.ID), models.HistoryMissed)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "20240204", history[0].Date)
+		assert.Equal(t, workout.ID, history[0].TaskID)
+		assert.NotEmpty(t, history[0].ID)
+	}
+	history, err = repo.GetHistory(0, models.HistoryMissed)
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	history, err = repo.GetHistory(0, "ooops")
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+// testRepositoryCompletions проверяет, что история выполнения возвращается начиная с последних выполненных задач
+func testRepositoryCompletions(t *testing.T, repo database.Repository) {
+	added := insertReports(t, repo)
+	review, workout := added[0], added[1]
+	workout = completeOccurrence(t, repo, workout, "20240202 09:15", "20240203")
+	completeOccurrence(t, repo, review, "20240203 18:00", "20240203")
+	workout = completeOccurrence(t, repo, workout, "20240203 23:59", "20240204")
+	done := func(taskID int, from string, to string) []string {
+		completions, err := repo.GetCompletions(taskID, from, to)
+		assert.NoError(t, err)
+		times := []string{}
+		for _, completion := range completions {
+			assert.NotEmpty(t, completion.ID)
+			times = append(times, completion.CompletedAt)
+		}
+		return times
+	}
+	assert.Equal(t, []string{"20240203 23:59", "20240203 18:00", "20240202 09:15"}, done(0, "", ""))
+	assert.Equal(t, []string{"20240203 23:59", "20240202 09:15"}, done(mustAtoi(t, workout.ID), "", ""))
+	assert.Equal(t, []string{"20240203 23:59", "20240203 18:00"}, done(0, "20240203", ""))
+	assert.Equal(t, []string{"20240202 09:15"}, done(0, "", "20240202"))
+	assert.Equal(t, []string{"20240203 23:59", "20240203 18:00"}, done(0, "20240203", "20240203"))
+	assert.Empty(t, done(0, "20240204", ""))
+	completions, err := repo.GetCompletions(mustAtoi(t, review.ID), "", "")
+	assert.NoError(t, err)
+	if assert.Len(t, completions, 1) {
+		assert.Equal(t, models.Completion{ID: completions[0].ID, TaskID: review.ID, Date: "20240202", Time: "10:00",
+			CompletedAt: "20240203 18:00", Title: "Review", Comment: "Code review", Repeat: "d 1"}, completions[0])
+	}
+
+	// выполнение записывается вместе с переносом задачи на следующее повторение
+	next := workout
+	next.Date = "20240206"
+	missed := []models.HistoryEntry{{TaskID: workout.ID, Date: "20240205", Time: "09:00", Title: "Зарядка", Status: models.HistoryMissed}}
+	completion := models.Completion{TaskID: workout.ID, Date: "20240204", Time: "09:00", CompletedAt: "20240206 08:00", Title: "Зарядка"}
+	assert.NoError(t, repo.CompleteTask(completion, missed, &next))
+	task, err := repo.GetTaskByID(mustAtoi(t, workout.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, next, task)
+	history, err := repo.GetHistory(mustAtoi(t, workout.ID), models.HistoryMissed)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+	// повтор запроса с прежней датой задачи ничего не записывает
+	assert.ErrorIs(t, repo.CompleteTask(completion, missed, &next), database.ErrTaskChanged)
+	assert.Equal(t, []string{"20240206 08:00", "20240203 23:59", "20240202 09:15"}, done(mustAtoi(t, workout.ID), "", ""))
+
+	// разовая задача отмечается выполненной и не попадает в корзину
+	report := added[3]
+	completion = models.Completion{TaskID: report.ID, Date: report.Date, CompletedAt: "20240301 12:00", Title: report.Title}
+	assert.NoError(t, repo.CompleteTask(completion, nil, nil))
+	_, err = repo.GetTaskByID(mustAtoi(t, report.ID))
+	assert.EqualError(t, err, "task not found")
+	assert.NotContains(t, listTitles(t, repo, database.TaskQuery{}), "Отчёт")
+	trashed, err := repo.GetTrash(database.TrashQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, trashed.Tasks)
+	assert.EqualError(t, repo.DeleteTaskByID(mustAtoi(t, report.ID)), "task not found")
+	assert.EqualError(t, repo.CompleteTask(completion, nil, nil), "task not found")
+	assert.Equal(t, []string{"20240301 12:00"}, done(mustAtoi(t, report.ID), "", ""))
+	revisions, err := repo.GetRevisions(mustAtoi(t, report.ID))
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, revisions) {
+		assert.Equal(t, models.RevisionDone, revisions[0].Action)
+		assert.Contains(t, revisions[0].NewTask, `"completed_at":"20240301 12:00"`)
+	}
+}
+
+// testRepositoryTrash проверяет, что удаленные задачи хранятся в корзине до восстановления или окончательного удаления
+func testRepositoryTrash(t *testing.T, repo database.Repository) {
+	added := insertTasks(t, repo, repositoryTasks()[:2]...)
+	deleted := mustAtoi(t, added[1].ID)
+	assert.NoError(t, repo.DeleteTaskByID(deleted))
+	trashed, err := repo.GetTrash(database.TrashQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, trashed.Total)
+	if assert.Len(t, trashed.Tasks, 1) {
+		assert.Equal(t, added[1].ID, trashed.Tasks[0].ID)
+		assert.NotEmpty(t, trashed.Tasks[0].DeletedAt)
+	}
+	assert.EqualError(t, repo.DeleteTaskByID(deleted), "task not found")
+	assert.EqualError(t, repo.UpdateTask(added[1]), "task not found")
+	assert.EqualError(t, repo.RestoreTask(mustAtoi(t, added[0].ID)), "task not found")
+	assert.NoError(t, repo.RestoreTask(deleted))
+	assert.EqualError(t, repo.RestoreTask(deleted), "task not found")
+	task, err := repo.GetTaskByID(deleted)
+	assert.NoError(t, err)
+	assert.Equal(t, added[1], task)
+	assert.Equal(t, []string{"Купить молоко"}, searchTitles(t, repo, "молоко"))
+
+	// окончательно удаляются задачи вместе с историей повторений и выполнения, а в истории изменений остаётся ревизия удаления
+	for _, task := range added {
+		completeOccurrence(t, repo, task, "20240205 12:00", "20240210",
+			models.HistoryEntry{TaskID: task.ID, Date: "20240204", Title: task.Title, Status: models.HistoryMissed})
+	}
+	assert.NoError(t, repo.DeleteTaskByID(deleted))
+	purged, err := trash.Purge(repo, time.Hour, time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = trash.Purge(repo, time.Hour, time.Now().Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.EqualError(t, repo.RestoreTask(deleted), "task not found")
+	trashed, err = repo.GetTrash(database.TrashQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, trashed.Tasks)
+	assert.Zero(t, trashed.Total)
+
+	history, err := repo.GetHistory(0, models.HistoryMissed)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, added[0].ID, history[0].TaskID)
+	}
+	completions, err := repo.GetCompletions(0, "", "")
+	assert.NoError(t, err)
+	if assert.Len(t, completions, 1) {
+		assert.Equal(t, added[0].ID, completions[0].TaskID)
+	}
+	revisions, err := repo.GetRevisions(deleted)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, revisions) {
+		assert.Equal(t, models.RevisionPurge, revisions[0].Action)
+		assert.Contains(t, revisions[0].OldTask, `"title":"Купить молоко"`)
+	}
+
+	// корзина возвращается страницами, начиная с удаленных последними
+	var ids []string
+	for _, task := range insertTasks(t, repo, repositoryTasks()[:3]...) {
+		assert.NoError(t, repo.DeleteTaskByID(mustAtoi(t, task.ID)))
+		ids = append([]string{task.ID}, ids...)
+	}
+	var pages []string
+	query := database.TrashQuery{Limit: 2}
+	for {
+		page, err := repo.GetTrash(query)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, page.Total)
+		for _, task := range page.Tasks {
+			pages = append(pages, task.ID)
+		}
+		if page.NextCursor == "" || len(pages) > len(ids) {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, ids, pages)
+	_, err = repo.GetTrash(database.TrashQuery{Cursor: "ooops"})
+	assert.ErrorIs(t, err, database.ErrInvalidCursor)
+	// курсор списка задач не является курсором корзины
+	insertTasks(t, repo, repositoryTasks()[2])
+	page, err := repo.GetTasks(database.TaskQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, page.NextCursor)
+	_, err = repo.GetTrash(database.TrashQuery{Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, database.ErrInvalidCursor)
+}
+
+// testRepositoryRevisions проверяет, что каждое изменение задачи записывается в историю изменений от имени пользователя
+func testRepositoryRevisions(t *testing.T, repo database.Repository) {
+	other := insertTasks(t, repo, repositoryTasks()[0])[0]
+	alice := repo.WithActor("alice")
+	inserted, err := alice.InsertTask(models.Task{Date: "20240301", Title: "Отчёт", RepeatFrom: "schedule", Overdue: "skip"})
+	assert.NoError(t, err)
+	id := int(inserted)
+	task, err := alice.GetTaskByID(id)
+	assert.NoError(t, err)
+	original := task
+	task.Title, task.Comment = "Годовой отчёт", "До пятницы"
+	assert.NoError(t, repo.UpdateTask(task))
+	assert.NoError(t, alice.DeleteTaskByID(id))
+	assert.NoError(t, alice.RestoreTask(id))
+
+	revisions, err := repo.GetRevisions(id)
+	assert.NoError(t, err)
+	actions := func() []string {
+		list := []string{}
+		for _, revision := range revisions {
+			assert.NotEmpty(t, revision.ID)
+			assert.Equal(t, fmt.Sprint(id), revision.TaskID)
+			assert.Len(t, revision.ChangedAt, len("20060102 15:04"))
+			list = append(list, revision.Action+" "+revision.Actor)
+		}
+		return list
+	}
+	assert.Equal(t, []string{"restore alice", "delete alice", "update ", "insert alice"}, actions())
+	if assert.Len(t, revisions, 4) {
+		assert.Empty(t, revisions[3].OldTask)
+		assert.Contains(t, revisions[2].OldTask, `"title":"Отчёт"`)
+		assert.Contains(t, revisions[2].NewTask, `"title":"Годовой отчёт"`)
+	}
+
+	// возврат к ревизии добавления задачи записывается новой ревизией
+	assert.NoError(t, repo.WithActor("bob").RevertTask(id, mustAtoi(t, revisions[3].ID)))
+	task, err = repo.GetTaskByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, original, task)
+	revisions, err = repo.GetRevisions(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"revert bob", "restore alice", "delete alice", "update ", "insert alice"}, actions())
+	assert.EqualError(t, repo.RevertTask(id, 1000000), "revision not found")
+	assert.EqualError(t, repo.RevertTask(mustAtoi(t, other.ID), mustAtoi(t, revisions[0].ID)), "revision not found")
+	revisions, err = repo.GetRevisions(1000000)
+	assert.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func mustAtoi(t *testing.T, value string) int {
+	var id int
+	_, err := fmt.Sscan(value, &id)
+	assert.NoError(t, err)
+	return id
+}
+
+func TestRepository(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testRepository(t, func(t *testing.T) database.Repository {
+			return database.NewMemoryStore()
+		})
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		testRepository(t, func(t *testing.T) database.Repository {
+			db, err := sqlx.Connect("sqlite", filepath.Join(t.TempDir(), "scheduler.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			if _, err := migrations.Up(db, false); err != nil {
+				t.Fatal(err)
+			}
+			return database.NewTasksStore(db)
+		})
+	})
+}
+
+func TestHandlersMemoryStore(t *testing.T) {
+	store := database.NewMemoryStore()
+	serve := func(handler http.HandlerFunc, method string, target string, body string) map[string]any {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &m), w.Body.String())
+		return m
+	}
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	ret := serve(handlers.PostTask(store), http.MethodPost, "/api/task",
+		`{"date":"`+date+`","title":"Полить цветы","repeat":"d 3"}`)
+	id := fmt.Sprint(ret["id"])
+	assert.Equal(t, "1", id)
+
+	ret = serve(handlers.GetTaskByID(store), http.MethodGet, "/api/task?id="+id, "")
+	assert.Equal(t, date, ret["date"])
+	assert.Equal(t, "каждые 3 дня", ret["repeat_text"])
+
+	assert.Empty(t, serve(handlers.PostTaskDone(store), http.MethodPost, "/api/task/done?id="+id, ""))
+	task, err := store.GetTaskByID(1)
+	assert.NoError(t, err)
+	assert.Greater(t, task.Date, date)
+
+	assert.Empty(t, serve(handlers.DeleteTask(store), http.MethodDelete, "/api/task?id="+id, ""))
+	assert.NotEmpty(t, serve(handlers.GetTaskByID(store), http.MethodGet, "/api/task?id="+id, "")["error"])
+}
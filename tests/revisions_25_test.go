@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/database"
+	"github.com/FausT-VX/todo-list-server/handlers"
+	"github.com/FausT-VX/todo-list-server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func getRevisions(t *testing.T, id string) []map[string]any {
+	body, err := requestJSON("api/task/history?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m map[string][]map[string]any
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m["revisions"]
+}
+
+func TestRevisions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 3).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "Подготовить отчёт", comment: "Квартальный"})
+	ret, err := postJSON("api/task", map[string]any{
+		"id":      id,
+		"date":    date,
+		"title":   "Подготовить годовой отчёт",
+		"comment": "Квартальный",
+		"repeat":  "d 7",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	// изменения задачи возвращаются начиная с последних, с прежними и новыми значениями полей
+	revisions := getRevisions(t, id)
+	if !assert.Len(t, revisions, 2) {
+		return
+	}
+	assert.Equal(t, "update", revisions[0]["action"])
+	assert.Equal(t, "insert", revisions[1]["action"])
+	assert.Len(t, revisions[0]["changed_at"], len("20060102 15:04"))
+	assert.Equal(t, []any{
+		map[string]any{"field": "title", "old": "Подготовить отчёт", "new": "Подготовить годовой отчёт"},
+		map[string]any{"field": "repeat", "old": "", "new": "d 7"},
+	}, revisions[0]["changes"])
+	assert.Contains(t, revisions[1]["changes"], map[string]any{"field": "title", "old": "", "new": "Подготовить отчёт"})
+
+	// возврат задачи к значениям после добавления
+	ret, err = postJSON(fmt.Sprintf("api/task/revert?id=%s&revision=%v", id, revisions[1]["id"]), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Подготовить отчёт", task.Title)
+	assert.Empty(t, task.Repeat)
+	revisions = getRevisions(t, id)
+	if assert.Len(t, revisions, 3) {
+		assert.Equal(t, "revert", revisions[0]["action"])
+	}
+
+	for _, path := range []string{"api/task/revert?id=" + id + "&revision=1000000", "api/task/revert?id=" + id,
+		"api/task/revert?id=ooops&revision=1"} {
+		ret, err = postJSON(path, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], path)
+	}
+
+	// задача в корзине не возвращается к значениям ревизии
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON(fmt.Sprintf("api/task/revert?id=%s&revision=%v", id, revisions[1]["id"]), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	assert.Len(t, getRevisions(t, id), 4)
+
+	ret, err = postJSON("api/task/history?id=ooops", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+// TestRevertTaskStatus проверяет коды ответа на возврат задачи к ревизии: несуществующие задача и ревизия не являются
+// ошибками хранилища
+func TestRevertTaskStatus(t *testing.T) {
+	store := database.NewMemoryStore()
+	_, err := store.InsertTask(models.Task{Date: "20240201", Title: "Отчёт", RepeatFrom: "schedule", Overdue: "skip"})
+	assert.NoError(t, err)
+	revert := func(target string) int {
+		w := httptest.NewRecorder()
+		handlers.RevertTask(store)(w, httptest.NewRequest(http.MethodPost, target, nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, revert("/api/task/revert?id=1&revision=1"))
+	assert.Equal(t, http.StatusNotFound, revert("/api/task/revert?id=1&revision=100"))
+	assert.Equal(t, http.StatusNotFound, revert("/api/task/revert?id=2&revision=1"))
+	assert.Equal(t, http.StatusBadRequest, revert("/api/task/revert?id=ooops&revision=1"))
+
+	assert.NoError(t, store.DeleteTaskByID(1))
+	assert.Equal(t, http.StatusNotFound, revert("/api/task/revert?id=1&revision=1"))
+}
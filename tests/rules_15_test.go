@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/service/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepeatRules(t *testing.T) {
+	body, err := requestJSON("api/repeat/rules?lang=en", nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m map[string][]scheduler.RuleInfo
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+
+	rules := map[string]scheduler.RuleInfo{}
+	for _, rule := range m["rules"] {
+		rules[rule.Name] = rule
+		assert.NotEmpty(t, rule.Syntax, rule.Name)
+		assert.NotEmpty(t, rule.Description, rule.Name)
+	}
+	for _, name := range []string{"d", "w", "m", "n", "y", "h", "min"} {
+		assert.Contains(t, rules, name)
+	}
+	assert.Equal(t, "every Monday and Thursday", rules["w"].Description)
+	assert.Contains(t, rules["d"].Modifiers, scheduler.ModBusinessDays)
+	assert.Contains(t, rules["m"].Modifiers, scheduler.ModEvery)
+	assert.True(t, rules["h"].TimeBased)
+}
+
+// quarterRule - правило "q <день> [bd]": повторение в заданный день первого месяца каждого квартала,
+// с модификатором bd - в заданный рабочий день (с понедельника по пятницу)
+type quarterRule struct{}
+
+func (quarterRule) Info() scheduler.RuleInfo {
+	return scheduler.RuleInfo{Name: "q", Syntax: "q <1-28> [bd]", Example: "q 15", Modifiers: []string{scheduler.ModBusinessDays}}
+}
+
+func (quarterRule) Parse(rules *scheduler.RepeatRules, value string) error {
+	nums, err := scheduler.ParseNums(value)
+	if err != nil {
+		return err
+	}
+	rules.AddArg(nums)
+	return nil
+}
+
+func (quarterRule) Validate(rules scheduler.RepeatRules, tokens []string) error {
+	if len(rules.Args()) != 1 || len(rules.Args()[0]) != 1 {
+		return &scheduler.RuleError{Token: 1, Reason: "one day is required for date part 'q'"}
+	}
+	if day := rules.Args()[0][0]; day < 1 || day > 28 {
+		return &scheduler.RuleError{Token: 1, Value: tokens[1], Reason: "day out of range"}
+	}
+	return nil
+}
+
+func (quarterRule) Next(rules scheduler.RepeatRules, begDate time.Time, after time.Time) (time.Time, error) {
+	y, m, _ := after.Date()
+	first := time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, after.Location())
+	for {
+		next := first.AddDate(0, 0, rules.Args()[0][0]-1)
+		if rules.BusinessDays() {
+			next = first
+			for days := 0; ; next = next.AddDate(0, 0, 1) {
+				if next.Weekday() != time.Saturday && next.Weekday() != time.Sunday {
+					if days++; days == rules.Args()[0][0] {
+						break
+					}
+				}
+			}
+		}
+		if next.After(after) {
+			return next, nil
+		}
+		first = first.AddDate(0, 3, 0)
+	}
+}
+
+func (quarterRule) Describe(rules scheduler.RepeatRules, lang string) string {
+	day := "day "
+	if rules.BusinessDays() {
+		day = "business day "
+	}
+	return day + strconv.Itoa(rules.Args()[0][0]) + " of every quarter"
+}
+
+func TestRegisterRule(t *testing.T) {
+	scheduler.Register(quarterRule{})
+	assert.Contains(t, scheduler.PossibleVals(), "q")
+	assert.Panics(t, func() { scheduler.Register(quarterRule{}) })
+
+	next, err := scheduler.NextDate("20240126", "20240101", "q 15")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240415", next)
+	next, err = scheduler.NextDate("20240126", "20240101", "q 15 fwd")
+	assert.Error(t, err, next)
+	_, err = scheduler.NextDate("20240126", "20240101", "q 30")
+	assert.Error(t, err)
+	// модификаторы правила доступны реализации, зарегистрированной вне пакета
+	next, err = scheduler.NextDate("20240126", "20240101", "q 10 bd")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240412", next)
+	description, err := scheduler.Describe("q 10 bd", scheduler.LangEN)
+	assert.NoError(t, err)
+	assert.Equal(t, "business day 10 of every quarter", description)
+
+	description, err = scheduler.Describe("q 15", scheduler.LangEN)
+	assert.NoError(t, err)
+	assert.Equal(t, "day 15 of every quarter", description)
+}
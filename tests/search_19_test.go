@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchFullText(t *testing.T) {
+	if !Search {
+		return
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	addTask(t, task{date: date, title: "Оплатить Интернет", comment: "до конца месяца"})
+	addTask(t, task{date: date, title: "Позвонить маме"})
+	id := addTask(t, task{date: date, title: "Купить подарок", comment: "Маме на день рождения"})
+
+	// поиск без учета регистра кириллицы и по началу слов
+	tasks := getTasks(t, url.QueryEscape("ИНТЕРН"))
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, "Оплатить <mark>Интернет</mark>", tasks[0]["snippet"])
+	}
+	tasks = getTasks(t, url.QueryEscape("мам"))
+	assert.Len(t, tasks, 2)
+	tasks = getTasks(t, url.QueryEscape("маме день"))
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, id, tasks[0]["id"])
+		assert.Equal(t, "<mark>Маме</mark> на <mark>день</mark> рождения", tasks[0]["snippet"])
+	}
+
+	// индекс поиска обновляется при изменении и удалении задач
+	_, err = db.Exec("UPDATE scheduler SET title = 'Купить цветы' WHERE id = ?", id)
+	assert.NoError(t, err)
+	assert.Len(t, getTasks(t, url.QueryEscape("цвет")), 1)
+	_, err = db.Exec("DELETE FROM scheduler WHERE id = ?", id)
+	assert.NoError(t, err)
+	assert.Empty(t, getTasks(t, url.QueryEscape("цвет")))
+
+	// задачи без поиска не содержат фрагментов
+	for _, task := range getTasks(t, "") {
+		assert.Empty(t, task["snippet"])
+	}
+}
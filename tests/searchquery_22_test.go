@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/FausT-VX/todo-list-server/service/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQueryParse(t *testing.T) {
+	query, err := search.Parse(`repeat:w due:<2024-03-01 "Годовой  отчёт" -черновик title:Q1,итоги`)
+	assert.NoError(t, err)
+	assert.Equal(t, []search.Term{
+		{Field: search.FieldRepeat, Value: "w"},
+		{Field: search.FieldDate, Op: "<", Value: "20240301"},
+		{Words: []string{"годовой", "отчёт"}, Phrase: true},
+		{Negate: true, Words: []string{"черновик"}},
+		{Field: search.FieldTitle, Words: []string{"q1", "итоги"}},
+	}, query.Terms)
+	assert.Len(t, query.TextTerms(false), 2)
+	assert.Len(t, query.TextTerms(true), 1)
+
+	for value, op := range map[string]string{
+		"date:20240301": "=", "date:=01.03.2024": "=", "date:<=2024-03-01": "<=", "DUE:>20240301": ">", "due:>=20240301": ">=",
+	} {
+		query, err := search.Parse(value)
+		if assert.NoError(t, err, value) && assert.Len(t, query.Terms, 1) {
+			assert.Equal(t, search.Term{Field: search.FieldDate, Op: op, Value: "20240301"}, query.Terms[0], value)
+		}
+	}
+
+	// слова без букв и цифр пропускаются, а двоеточие после слова не на латинице не задает поле
+	query, err = search.Parse(" !!! время:12 ")
+	assert.NoError(t, err)
+	assert.Equal(t, []search.Term{{Words: []string{"время", "12"}}}, query.Terms)
+
+	for value, expected := range map[string]search.ParseError{
+		"отчёт -":              {Pos: 7, Token: "-", Reason: "nothing to negate"},
+		"отчёт owner:me":       {Pos: 7, Token: "owner:", Reason: "unknown field, must be title, comment, date, due or repeat"},
+		`отчёт "годовой`:       {Pos: 7, Token: `"годовой`, Reason: "unterminated quoted phrase"},
+		"title: отчёт":         {Pos: 1, Token: "title:", Reason: "missing value"},
+		"date:<2024":           {Pos: 1, Token: "date:<2024", Reason: "invalid date, must be in format 2006-01-02, 20060102 or 02.01.2006"},
+		`comment:"!!!"`:        {Pos: 1, Token: `comment:"!!!"`, Reason: "no words to search"},
+		"-отчёт repeat:weekly": {Pos: 8, Token: "repeat:weekly"},
+	} {
+		_, err := search.Parse(value)
+		var parseErr *search.ParseError
+		if assert.ErrorAs(t, err, &parseErr, value) {
+			assert.Equal(t, expected.Pos, parseErr.Pos, value)
+			assert.Equal(t, expected.Token, parseErr.Token, value)
+			if expected.Reason != "" {
+				assert.Equal(t, expected.Reason, parseErr.Reason, value)
+			}
+		}
+	}
+}
+
+func TestSearchQueryAPI(t *testing.T) {
+	if !Search {
+		return
+	}
+	page := getTasksPage(t, url.Values{"search": {`отчёт date:<01.13.2024`}})
+	assert.Equal(t, `invalid search query at position 7 ("date:<01.13.2024"): `+
+		"invalid date, must be in format 2006-01-02, 20060102 or 02.01.2006", page.Error)
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
@@ -52,6 +53,151 @@ func TestDone(t *testing.T) {
 	}
 }
 
+func TestDoneRepeatEnd(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	ret, err := postJSON("api/task", map[string]any{
+		"date":         now.Format(`20060102`),
+		"title":        "Пройти курс массажа",
+		"repeat":       "d 2",
+		"repeat_count": 2,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), task.Date)
+	assert.Equal(t, 1, task.RepeatCount)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":         now.Format(`20060102`),
+		"title":        "Принимать витамины",
+		"repeat":       "d 7",
+		"repeat_until": now.AddDate(0, 0, 10).Format(`20060102`),
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id = fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":         now.Format(`20060102`),
+		"title":        "Некорректное окончание",
+		"repeat":       "d 7",
+		"repeat_until": "ooops",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestDoneRepeatFromCompletion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	for _, from := range []string{"schedule", "completion"} {
+		res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat, repeat_from)
+		VALUES (?, 'Полить цветы', '', 'd 7', ?)`, now.AddDate(0, 0, -10).Format(`20060102`), from)
+		assert.NoError(t, err)
+		id, _ := res.LastInsertId()
+
+		ret, err := postJSON(fmt.Sprintf("api/task/done?id=%d", id), nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		want := now.AddDate(0, 0, 4) // от запланированной даты: -10 + 14
+		if from == "completion" {
+			want = now.AddDate(0, 0, 7) // от даты выполнения
+		}
+		assert.Equal(t, want.Format(`20060102`), task.Date, from)
+
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"title":       "Неизвестный режим",
+		"repeat":      "d 7",
+		"repeat_from": "ooops",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestDoneHourly(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	start := now.Add(-5 * time.Hour).Truncate(time.Minute)
+	id := addTask(t, task{
+		date:   start.Format(`20060102`),
+		title:  "Проверить резервные копии",
+		repeat: "h 4",
+	})
+
+	// время передаётся отдельным полем
+	ret, err := postJSON("api/task", map[string]any{
+		"id":     id,
+		"date":   start.Format(`20060102`),
+		"time":   start.Format(`15:04`),
+		"title":  "Проверить резервные копии",
+		"repeat": "h 4",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	want := start.Add(8 * time.Hour) // прошедшее время сдвигается на ближайшее будущее повторение
+	assert.Equal(t, want.Format(`20060102`), task.Date)
+	assert.Equal(t, want.Format(`15:04`), task.Time)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	want = want.Add(4 * time.Hour)
+	assert.Equal(t, want.Format(`20060102`), task.Date)
+	assert.Equal(t, want.Format(`15:04`), task.Time)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"title": "Некорректное время",
+		"date":  now.Format(`20060102`),
+		"time":  "25:00",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
+
 func TestDelTask(t *testing.T) {
 	db := openDB(t)
 	defer db.Close()
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/service/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	// 31.03.2024 в 02:00 часы переводятся на 03:00, 27.10.2024 в 03:00 - обратно на 02:00
+	tbl := []struct {
+		now    string
+		date   string
+		repeat string
+		loc    *time.Location
+		want   string
+	}{
+		{"20240331 01:30", "20240331 01:00", "h 1", berlin, "20240331 03:00"},
+		{"20240331 01:30", "20240331 01:00", "h 1", time.UTC, "20240331 02:00"},
+		{"20240330 12:00", "20240330 10:00", "h 24", berlin, "20240331 11:00"},
+		{"20240330 12:00", "20240329 10:00", "d 2", berlin, "20240331 10:00"},
+		{"20241027 01:45", "20241027 01:30", "h 2", berlin, "20241027 02:30"},
+		{"20241027 01:45", "20241027 01:30", "h 2", time.UTC, "20241027 03:30"},
+		{"20241026 12:00", "20241026 09:00", "w 1,7", berlin, "20241027 09:00"},
+		{"20240330", "20240301", "m 31", berlin, "20240331"},
+		{"20240330", "20240301", "n -1 7", berlin, "20240331"},
+	}
+	for _, v := range tbl {
+		next, err := scheduler.NextDateIn(v.now, v.date, v.repeat, v.loc)
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %v}`, v.now, v.date, v.repeat, v.loc)
+	}
+
+	// повторения по часам в интервале, включающем переход на летнее время
+	series := scheduler.Series{Date: "20240331 00:00", Repeat: "h 1", Location: berlin}
+	dates, err := series.Occurrences("20240331", "20240331")
+	assert.NoError(t, err)
+	assert.Len(t, dates, 23)
+	assert.Equal(t, "20240331 03:00", dates[2])
+}
+
+func TestTimeZone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// часовой пояс запроса
+	get, err := getBody("api/nextdate?now=" + url.QueryEscape("20240331 01:30") + "&date=" +
+		url.QueryEscape("20240331 01:00") + "&repeat=" + url.QueryEscape("h 1") + "&tz=Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240331 03:00", strings.TrimSpace(string(get)))
+
+	get, err = getBody("api/nextdate?now=20240126&date=20240126&repeat=d+1&tz=Mars/Olympus")
+	assert.NoError(t, err)
+	assert.Contains(t, string(get), "error")
+
+	// часовой пояс задачи: пустая дата заменяется текущей датой в поясе задачи
+	for _, tz := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		loc, err := time.LoadLocation(tz)
+		assert.NoError(t, err)
+		ret, err := postJSON("api/task", map[string]any{
+			"title": "Созвон с командой",
+			"tz":    tz,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		id := fmt.Sprint(ret["id"])
+		assert.NotEmpty(t, id)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Now().In(loc).Format(`20060102`), task.Date, tz)
+		assert.Equal(t, tz, task.TimeZone)
+
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"title": "Неизвестный часовой пояс",
+		"tz":    "Mars/Olympus",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTrash(t *testing.T) []map[string]any {
+	body, err := requestJSON("api/trash", nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m.Tasks
+}
+
+func TestTrash(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	trashed := func(id string) bool {
+		for _, task := range getTrash(t) {
+			if fmt.Sprint(task["id"]) == id {
+				assert.NotEmpty(t, task["deleted_at"])
+				return true
+			}
+		}
+		return false
+	}
+
+	id := addTask(t, task{date: time.Now().Format(`20060102`), title: "Разобрать почту"})
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+	assert.True(t, trashed(id))
+
+	// корзина возвращается страницами, начиная с удаленных последними
+	body, err := requestJSON("api/trash?limit=1", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var page struct {
+		Tasks      []map[string]any `json:"tasks"`
+		NextCursor *string          `json:"next_cursor"`
+		Total      int              `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &page))
+	if assert.Len(t, page.Tasks, 1) {
+		assert.Equal(t, id, fmt.Sprint(page.Tasks[0]["id"]))
+	}
+	assert.NotNil(t, page.NextCursor)
+	assert.Positive(t, page.Total)
+	for _, path := range []string{"api/trash?limit=0", "api/trash?limit=ooops", "api/trash?cursor=ooops"} {
+		ret, err = postJSON(path, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], path)
+	}
+
+	// удаленная задача остается в базе данных до окончательного удаления
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, task.DeletedAt)
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.False(t, trashed(id))
+	body, err = requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &ret))
+	assert.Equal(t, "Разобрать почту", ret["title"])
+	assert.Nil(t, ret["deleted_at"])
+
+	for _, path := range []string{"api/task/restore?id=" + id, "api/task/restore?id=", "api/task/restore?id=ooops"} {
+		ret, err = postJSON(path, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], path)
+	}
+
+	// выполненная разовая задача не попадает в корзину, а отмечается выполненной
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+	assert.False(t, trashed(id))
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Empty(t, task.DeletedAt)
+	assert.NotEmpty(t, task.CompletedAt)
+}
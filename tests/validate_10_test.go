@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FausT-VX/todo-list-server/service/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRepeat(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		valid  bool
+		token  float64
+		reason string
+	}{
+		{"m 10,17 12,13", false, 2, "month 13 out of range"},
+		{"k 34", false, 0, `unknown date part "k"`},
+		{"d", false, 1, "missing argument for date part 'd'"},
+		{"d 401", false, 1, "interval 401 out of range"},
+		{"w 1,x", false, 1, `value "x" is not a number`},
+		{"y 5", false, 1, `value "5" is not a date in format DD.MM`},
+		{"y 05.03 06.03", false, 2, "unexpected argument for date part 'y'"},
+		{"y 30.02", false, 1, "day 30 out of range for month 2"},
+		{"n 2 2 1 5", false, 4, "unexpected argument for date part 'n'"},
+		{"n 2 2", true, 0, ""},
+		{"d 7", true, 0, ""},
+	}
+	for _, v := range tbl {
+		body, err := requestJSON("api/repeat/validate", map[string]any{
+			"repeat": v.repeat,
+			"date":   "20240126",
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+
+		assert.Equal(t, v.valid, m["valid"], "Правило %q", v.repeat)
+		if !v.valid {
+			assert.Equal(t, v.token, m["token"], "Правило %q", v.repeat)
+			assert.Equal(t, v.reason, m["reason"], "Правило %q", v.repeat)
+			continue
+		}
+		next, ok := m["next"].([]any)
+		assert.True(t, ok)
+		assert.Len(t, next, 5, "Правило %q", v.repeat)
+	}
+}
+
+func TestUpcomingDates(t *testing.T) {
+	tbl := []struct {
+		date   string
+		repeat string
+		want   []string
+	}{
+		// дата начала, не подходящая под правило, в список не входит
+		{"20261021", "w 1", []string{"20261026", "20261102", "20261109"}},
+		{"20261021", "m 1", []string{"20261101", "20261201", "20270101"}},
+		{"20261021", "n 1 1", []string{"20261102", "20261207", "20270104"}},
+		{"20261021", "d 7", []string{"20261021", "20261028", "20261104"}},
+		{"20261021 09:00", "h 4", []string{"20261021 09:00", "20261021 13:00", "20261021 17:00"}},
+		// повторения прошедшей даты начала перечисляются с даты now
+		{"20240101", "w 1", []string{"20261019", "20261026", "20261102"}},
+	}
+	for _, v := range tbl {
+		dates, err := scheduler.UpcomingDates("20261017 10:00", v.date, v.repeat, 3, time.UTC)
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.want, dates, "Правило %q с даты %s", v.repeat, v.date)
+	}
+}